@@ -2,8 +2,12 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/vearutop/plt/curl"
@@ -17,14 +21,29 @@ func main() {
 	lf := loadgen.Flags{}
 	lf.Register()
 
-	var cardinality, group int
+	var (
+		cardinality, group int
+		scenario           string
+		probe              healthProbe
+	)
 
 	kingpin.Flag("cardinality", "Number of different urls to send.").Default("1000").IntVar(&cardinality)
 	kingpin.Flag("group", "Number of sequential requests to group in single URL.").Default("10").IntVar(&group)
+	kingpin.Flag("scenario", "Load scenario, build-limit sends a unique key with every request and probes database through the server.").
+		Default("hello").EnumVar(&scenario, "hello", "build-limit")
 
 	curl.AddCommand(&lf, func(_ *loadgen.Flags, _ *nethttp.Flags, j loadgen.JobProducer) {
 		if nj, ok := j.(*nethttp.JobProducer); ok {
 			nj.PrepareRequest = func(i int, req *http.Request) error {
+				if scenario == "build-limit" {
+					probe.start(req.URL)
+
+					// Every request misses cache and needs a build.
+					req.URL.RawQuery = "locale=en-US&name=load" + strconv.Itoa(i)
+
+					return nil
+				}
+
 				k := i / group
 				req.URL.RawQuery = "locale=en-US&name=user" + strconv.Itoa(k%cardinality)
 
@@ -34,4 +53,74 @@ func main() {
 	})
 
 	kingpin.Parse()
+
+	if scenario == "build-limit" {
+		probe.report()
+	}
+}
+
+// healthProbe reads a page of stored greetings during load to check that database stays responsive.
+type healthProbe struct {
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+
+	ok, failed int
+	slowest    time.Duration
+}
+
+func (p *healthProbe) start(u *url.URL) {
+	p.once.Do(func() {
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+
+		greetings := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/greetings", RawQuery: "limit=1"}
+
+		go p.run(greetings.String())
+	})
+}
+
+func (p *healthProbe) run(u string) {
+	defer close(p.done)
+
+	client := http.Client{Timeout: 5 * time.Second}
+	ticker := time.NewTicker(time.Second)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+
+		resp, err := client.Get(u) //nolint:noctx // Probe is bounded by client timeout.
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+
+		if elapsed := time.Since(start); elapsed > p.slowest {
+			p.slowest = elapsed
+		}
+
+		if err != nil || resp.StatusCode != http.StatusOK {
+			p.failed++
+		} else {
+			p.ok++
+		}
+	}
+}
+
+func (p *healthProbe) report() {
+	if p.stop == nil {
+		return
+	}
+
+	close(p.stop)
+	<-p.done
+
+	fmt.Printf("\nDatabase probes: %d ok, %d failed, slowest %s\n", p.ok, p.failed, p.slowest.String())
 }
@@ -0,0 +1,115 @@
+Feature: Locale negotiation
+
+  Scenario: Explicit locale takes precedence over Accept-Language.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Kim&locale=ru-RU"
+    And I request HTTP endpoint with header "Accept-Language: pt-BR"
+    Then I should have response with status "OK"
+    And I should have response with header "Content-Language: ru-RU"
+    And I should have response with header "Vary: Accept-Language"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Привет, Kim!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Default locale is used without Accept-Language.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Kim"
+    Then I should have response with status "OK"
+    And I should have response with header "Content-Language: en-US"
+    And I should have response with header "Vary: Accept-Language"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Hello, Kim!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Locale with highest quality is selected.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Kim"
+    And I request HTTP endpoint with header "Accept-Language: de-DE, pt-BR;q=0.5, ru;q=0.8"
+    Then I should have response with status "OK"
+    And I should have response with header "Content-Language: ru-RU"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Привет, Kim!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Region falls back to language and tags are case-insensitive.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Kim"
+    And I request HTTP endpoint with header "Accept-Language: PT-pt"
+    Then I should have response with status "OK"
+    And I should have response with header "Content-Language: pt"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Olá, Kim!","locale":"pt","templateVersion":0,"createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Unsupported locales fall back to default.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Kim"
+    And I request HTTP endpoint with header "Accept-Language: de-DE, fr;q=0.9"
+    Then I should have response with status "OK"
+    And I should have response with header "Content-Language: en-US"
+
+  Scenario: Zero quality excludes locale from wildcard.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Kim"
+    And I request HTTP endpoint with header "Accept-Language: en;q=0, *;q=0.5"
+    Then I should have response with status "OK"
+    And I should have response with header "Content-Language: pt"
+
+  Scenario: Malformed ranges are ignored.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Ray"
+    And I request HTTP endpoint with header "Accept-Language: ru;q=2, ,;q=1, pt-BR;level=1, ру, pt-BR;q=0.1"
+    Then I should have response with status "OK"
+    And I should have response with header "Content-Language: pt-BR"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Olá, Ray!","locale":"pt-BR","templateVersion":0,"createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Negotiated locale is a part of cache key.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Lee"
+    And I request HTTP endpoint with header "Accept-Language: ru-RU"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Привет, Lee!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Lee"
+    And I request HTTP endpoint with header "Accept-Language: en-US"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Hello, Lee!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
+    """
+
+    # Cached greeting is served for explicit locale.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Lee&locale=ru-RU"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Привет, Lee!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+    And no rows are available in table "greetings"
+
+  Scenario: Locale and its fallback with the same message are stored separately.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Ana&locale=pt"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Olá, Ana!","locale":"pt","templateVersion":0,"createdAt":"<ignore-diff>"}
+    """
+
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Ana&locale=pt-BR"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Olá, Ana!","locale":"pt-BR","templateVersion":0,"createdAt":"<ignore-diff>"}
+    """
+    And only these rows are available in table "greetings":
+      | message   | name | locale |
+      | Olá, Ana! | Ana  | pt     |
+      | Olá, Ana! | Ana  | pt-BR  |
+
+  Scenario: Failed response also varies by Accept-Language.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Kim&locale=zz-ZZ"
+    And I request HTTP endpoint with header "Accept-Language: pt-BR"
+    Then I should have response with status "Bad Request"
+    And I should have response with header "Vary: Accept-Language"
@@ -0,0 +1,72 @@
+Feature: Saved greetings
+
+  Scenario: Get greeting by ID.
+    Given there are no rows in table "greetings"
+    And these rows are stored in table "greetings":
+      | id | message     | name | locale | created_at           |
+      | 10 | Hello, Ann! | Ann  | en-US  | 2026-01-02T03:04:05Z |
+    When I request HTTP endpoint with method "GET" and URI "/greetings/10"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"id":10,"message":"Hello, Ann!","name":"Ann","locale":"en-US","createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Get missing greeting.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "GET" and URI "/greetings/10"
+    Then I should have response with status "Not Found"
+    And I should have response with body
+    """
+    {"status":"NOT_FOUND","error":"not found: greeting not found"}
+    """
+
+  Scenario: Delete greeting and rebuild only its cached value.
+    Given there are no rows in table "greetings"
+    And these rows are stored in table "greetings":
+      | id | message     | name | locale | created_at           |
+      | 10 | Hello, Ann! | Ann  | en-US  | 2026-01-02T03:04:05Z |
+      | 11 | Hello, Bob! | Bob  | en-US  | 2026-01-02T03:04:05Z |
+
+    # Warming up cache.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Ann&locale=en-US"
+    Then I should have response with status "OK"
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Bob&locale=en-US"
+    Then I should have response with status "OK"
+
+    When I request HTTP endpoint with method "DELETE" and URI "/greetings/10"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"id":10,"message":"Hello, Ann!","name":"Ann","locale":"en-US","createdAt":"<ignore-diff>"}
+    """
+    And only these rows are available in table "greetings":
+      | id | message     |
+      | 11 | Hello, Bob! |
+
+    # Rows are removed to check which greetings are built again.
+    Given there are no rows in table "greetings"
+
+    # Deleted greeting is built again and stored.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Ann&locale=en-US"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Hello, Ann!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
+    """
+
+    # Other greeting is still served from cache.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Bob&locale=en-US"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Hello, Bob!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
+    """
+    And only these rows are available in table "greetings":
+      | message     | name | locale |
+      | Hello, Ann! | Ann  | en-US  |
+
+  Scenario: Delete missing greeting.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "DELETE" and URI "/greetings/10"
+    Then I should have response with status "Not Found"
@@ -6,7 +6,7 @@ Feature: Hello, World!
     And I concurrently request idempotent HTTP endpoint
     Then I should have response with body
     """
-    {"message":"Hello, Jane!"}
+    {"id":"<ignore-diff>","message":"Hello, Jane!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
     """
     And I should have response with status "OK"
     And only these rows are available in table "greetings":
@@ -21,7 +21,7 @@ Feature: Hello, World!
     """
     {
      "status":"INVALID_ARGUMENT","error":"invalid argument: validation failed",
-     "context":{"query:locale":["#: value must be one of \"en-US\", \"ru-RU\""]}
+     "context":{"query:locale":["#: value must be one of \"en-US\", \"pt\", \"pt-BR\", \"ru-RU\""]}
     }
     """
     And I should have response with status "Bad Request"
@@ -0,0 +1,70 @@
+Feature: Batch of greetings
+
+  Scenario: Batch is served with per item failures.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "POST" and URI "/hello/batch"
+    And I request HTTP endpoint with header "Accept-Language: ru"
+    And I request HTTP endpoint with body
+    """
+    [
+     {"name":"Zoe","locale":"en-US"},
+     {"name":"Tom"},
+     {"name":"Bug","locale":"en-US"},
+     {"name":"Zoe","locale":"en-US"}
+    ]
+    """
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    [
+     {"greeting":{"id":"<ignore-diff>","message":"Hello, Zoe!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}},
+     {"greeting":{"id":"<ignore-diff>","message":"Привет, Tom!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}},
+     {"error":{"error":"#$@@^! %C 🤖","context":{"trace.id":"<ignore-diff>","transaction.id":"<ignore-diff>"}}},
+     {"greeting":{"id":"<ignore-diff>","message":"Hello, Zoe!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}}
+    ]
+    """
+    And only these rows are available in table "greetings":
+      | message      |
+      | Hello, Zoe!  |
+      | Привет, Tom! |
+
+    # Greetings are cached.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "POST" and URI "/hello/batch"
+    And I request HTTP endpoint with body
+    """
+    [{"name":"Tom","locale":"ru-RU"},{"name":"Zoe","locale":"en-US"}]
+    """
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    [
+     {"greeting":{"id":"<ignore-diff>","message":"Привет, Tom!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}},
+     {"greeting":{"id":"<ignore-diff>","message":"Hello, Zoe!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}}
+    ]
+    """
+    And no rows are available in table "greetings"
+
+    # Batch greetings are shared with single greetings.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Tom&locale=ru-RU"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Привет, Tom!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+    And no rows are available in table "greetings"
+
+  Scenario: Invalid item fails the batch.
+    When I request HTTP endpoint with method "POST" and URI "/hello/batch"
+    And I request HTTP endpoint with body
+    """
+    [{"name":"Zoe","locale":"en-US"},{"name":"Tom","locale":"zz-ZZ"}]
+    """
+    Then I should have response with status "Bad Request"
+    And I should have response with header "Vary: Accept-Language"
+    And I should have response with body
+    """
+    {
+     "status":"INVALID_ARGUMENT","error":"invalid argument: validation failed",
+     "context":{"body":["#/1: doesn't validate with \"#/components/schemas/GreetingParams\"","#/1/locale: value must be one of \"en-US\", \"pt\", \"pt-BR\", \"ru-RU\""]}
+    }
+    """
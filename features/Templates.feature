@@ -0,0 +1,145 @@
+Feature: Greeting templates
+
+  Scenario: List templates.
+    When I request HTTP endpoint with method "GET" and URI "/greeting-templates"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    [
+     {"locale":"en-US","text":"Hello, {name}!","version":"<ignore-diff>","updatedAt":"<ignore-diff>"},
+     {"locale":"ru-RU","text":"Привет, {name}!","version":1,"updatedAt":"<ignore-diff>"}
+    ]
+    """
+
+  Scenario: Get missing template.
+    When I request HTTP endpoint with method "GET" and URI "/greeting-templates/pt"
+    Then I should have response with status "Not Found"
+    And I should have response with body
+    """
+    {"status":"NOT_FOUND","error":"not found: greeting template not found"}
+    """
+
+  Scenario: Invalid template is rejected.
+    When I request HTTP endpoint with method "PUT" and URI "/greeting-templates/en-US"
+    And I request HTTP endpoint with body
+    """
+    {"text":"Hi!"}
+    """
+    Then I should have response with status "Bad Request"
+    And I should have response with body
+    """
+    {"status":"INVALID_ARGUMENT","error":"invalid argument: greeting template must have exactly one {name} placeholder"}
+    """
+
+  Scenario: Editing template invalidates greetings of its locale.
+    Given there are no rows in table "greetings"
+
+    # Warming up cache.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eve&locale=en-US"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Hello, Eve!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
+    """
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eve&locale=ru-RU"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Привет, Eve!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+
+    When I request HTTP endpoint with method "PUT" and URI "/greeting-templates/en-US"
+    And I request HTTP endpoint with body
+    """
+    {"text":"Hi, {name}!"}
+    """
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"locale":"en-US","text":"Hi, {name}!","version":"<ignore-diff>","updatedAt":"<ignore-diff>"}
+    """
+    And only these rows are available in table "greeting_templates":
+      | locale | template        |
+      | en-US  | Hi, {name}!     |
+      | ru-RU  | Привет, {name}! |
+
+    # Stored greetings of edited locale are removed.
+    And only these rows are available in table "greetings":
+      | message      |
+      | Привет, Eve! |
+
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eve&locale=en-US"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Hi, Eve!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
+    """
+
+    # Greetings of other locales stay cached.
+    Given there are no rows in table "greetings"
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eve&locale=ru-RU"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Привет, Eve!","locale":"ru-RU","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+    And no rows are available in table "greetings"
+
+    # Restoring original template.
+    When I request HTTP endpoint with method "PUT" and URI "/greeting-templates/en-US"
+    And I request HTTP endpoint with body
+    """
+    {"text":"Hello, {name}!"}
+    """
+    Then I should have response with status "OK"
+
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eve&locale=en-US"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Hello, Eve!","locale":"en-US","templateVersion":"<ignore-diff>","createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Locale falls back to parent language.
+    Given there are no rows in table "greetings"
+
+    # Default template of pt from locale catalog.
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eva&locale=pt-BR"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Olá, Eva!","locale":"pt-BR","templateVersion":0,"createdAt":"<ignore-diff>"}
+    """
+
+    When I request HTTP endpoint with method "PUT" and URI "/greeting-templates/pt"
+    And I request HTTP endpoint with body
+    """
+    {"text":"Oi, {name}!"}
+    """
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"locale":"pt","text":"Oi, {name}!","version":1,"updatedAt":"<ignore-diff>"}
+    """
+
+    # Greetings of dependent locales are invalidated.
+    And no rows are available in table "greetings"
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eva&locale=pt-BR"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Oi, Eva!","locale":"pt-BR","templateVersion":1,"createdAt":"<ignore-diff>"}
+    """
+
+    When I request HTTP endpoint with method "DELETE" and URI "/greeting-templates/pt"
+    Then I should have response with status "OK"
+
+    When I request HTTP endpoint with method "GET" and URI "/hello?name=Eva&locale=pt-BR"
+    Then I should have response with body
+    """
+    {"id":"<ignore-diff>","message":"Olá, Eva!","locale":"pt-BR","templateVersion":0,"createdAt":"<ignore-diff>"}
+    """
+
+  Scenario: Unknown locale is rejected.
+    When I request HTTP endpoint with method "GET" and URI "/greeting-templates/zz-ZZ"
+    Then I should have response with status "Bad Request"
+    And I should have response with body
+    """
+    {
+     "status":"INVALID_ARGUMENT","error":"invalid argument: validation failed",
+     "context":{"path:locale":["#: value must be one of \"en-US\", \"pt\", \"pt-BR\", \"ru-RU\""]}
+    }
+    """
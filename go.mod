@@ -3,6 +3,7 @@ module github.com/vearutop/cache-story
 go 1.21
 
 require (
+	github.com/Masterminds/squirrel v1.5.4
 	github.com/alecthomas/kingpin v2.2.6+incompatible
 	github.com/bool64/brick v0.2.5
 	github.com/bool64/cache v0.4.7
@@ -14,6 +15,7 @@ require (
 	github.com/go-sql-driver/mysql v1.7.1
 	github.com/godogx/dbsteps v0.1.2
 	github.com/stretchr/testify v1.8.4
+	github.com/swaggest/jsonschema-go v0.3.64
 	github.com/swaggest/rest v0.2.61
 	github.com/swaggest/usecase v1.3.1
 	github.com/valyala/fasthttp v1.52.0
@@ -26,7 +28,6 @@ require (
 	contrib.go.opencensus.io/exporter/jaeger v0.2.1 // indirect
 	contrib.go.opencensus.io/exporter/prometheus v0.4.2 // indirect
 	contrib.go.opencensus.io/integrations/ocsql v0.1.7 // indirect
-	github.com/Masterminds/squirrel v1.5.4 // indirect
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751 // indirect
 	github.com/alecthomas/units v0.0.0-20231202071711-9a357b53e9c9 // indirect
 	github.com/andybalholm/brotli v1.1.0 // indirect
@@ -95,7 +96,6 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/swaggest/assertjson v1.9.0 // indirect
 	github.com/swaggest/form/v5 v5.1.1 // indirect
-	github.com/swaggest/jsonschema-go v0.3.64 // indirect
 	github.com/swaggest/openapi-go v0.2.45 // indirect
 	github.com/swaggest/refl v1.3.0 // indirect
 	github.com/swaggest/swgui v1.8.0 // indirect
@@ -1,9 +1,12 @@
 package main_test
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/bool64/brick"
@@ -11,6 +14,7 @@ import (
 	"github.com/bool64/brick/test"
 	"github.com/bool64/httptestbench"
 	"github.com/godogx/dbsteps"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
 	"github.com/vearutop/cache-story/internal/infra"
@@ -33,6 +37,7 @@ func TestFeatures(t *testing.T) {
 		tc.Database.Instances[dbsteps.Default] = dbsteps.Instance{
 			Tables: map[string]interface{}{
 				storage.GreetingsTable: new(storage.GreetingRow),
+				storage.TemplatesTable: new(storage.TemplateRow),
 			},
 		}
 
@@ -40,6 +45,43 @@ func TestFeatures(t *testing.T) {
 	})
 }
 
+func TestHelloBatch_loader(t *testing.T) {
+	var cfg service.Config
+
+	require.NoError(t, config.Load("", &cfg, config.WithOptionalEnvFiles(".env.integration-test")))
+
+	cfg.ServiceName = service.Name
+	cfg.Cache = "advanced"
+	cfg.Database.DriverName = "sqlite"
+	cfg.Database.DSN = filepath.Join(t.TempDir(), "db.sqlite")
+	cfg.Database.MaxOpen = 1
+	cfg.Database.ApplyMigrations = true
+
+	sl, err := infra.NewServiceLocator(cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(nethttp.NewRouter(sl))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/hello/batch", "application/json",
+		strings.NewReader(`[{"name":"Ann","locale":"en-US"},{"name":"Bob","locale":"en-US"},{"name":"Ann","locale":"en-US"}]`))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+
+	metrics, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// Duplicate item is served by loader, cache backend is read once per greeting.
+	assert.Contains(t, string(metrics), `loader_hits{name="greetings"} 1`)
+	assert.Contains(t, string(metrics), `batch_items{name="greetings"} 2`)
+	assert.Contains(t, string(metrics), `cache_miss{name="greetings"} 2`)
+}
+
 // cpu: Intel(R) Core(TM) i7-9750H CPU @ 2.60GHz
 // cache: none
 // BenchmarkGreetings-12    	     670	   1860402 ns/op	        85.60 50%:ms	       158.7 90%:ms	       238.0 99%:ms	       260.4 99.9%:ms	       151.8 B:rcvd/op	        92.82 B:sent/op	       537.4 rps	   54264 B/op	     850 allocs/op
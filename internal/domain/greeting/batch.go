@@ -0,0 +1,85 @@
+package greeting
+
+import (
+	"context"
+	"sync"
+)
+
+// Result is an outcome of a batch item.
+type Result struct {
+	Greeting Greeting
+	Err      error
+}
+
+// BatchMaker makes many greetings at once.
+//
+// Results are in the order of params, failure of an item does not fail other items.
+type BatchMaker interface {
+	HelloBatch(ctx context.Context, params []Params) []Result
+}
+
+// ParallelMaker makes greetings of a batch one by one with Maker in parallel,
+// unless Maker is a BatchMaker itself.
+type ParallelMaker struct {
+	Maker Maker
+
+	// Concurrency limits parallel builds, builds are sequential if it is not positive.
+	Concurrency int
+}
+
+// HelloBatch greets many.
+func (m *ParallelMaker) HelloBatch(ctx context.Context, params []Params) []Result {
+	if bm, ok := m.Maker.(BatchMaker); ok {
+		return bm.HelloBatch(ctx, params)
+	}
+
+	return HelloEach(ctx, params, m.Concurrency, m.Maker.Hello)
+}
+
+// GreetingBatchMaker implements service provider.
+func (m *ParallelMaker) GreetingBatchMaker() BatchMaker {
+	if m == nil {
+		panic("empty ParallelMaker")
+	}
+
+	return m
+}
+
+// HelloEach calls hello for every params with at most concurrency parallel calls.
+func HelloEach(
+	ctx context.Context,
+	params []Params,
+	concurrency int,
+	hello func(ctx context.Context, params Params) (Greeting, error),
+) []Result {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		res = make([]Result, len(params))
+		sem = make(chan struct{}, concurrency)
+		wg  sync.WaitGroup
+	)
+
+	for i, p := range params {
+		i, p := i, p
+
+		sem <- struct{}{}
+
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			res[i].Greeting, res[i].Err = hello(ctx, p)
+		}()
+	}
+
+	wg.Wait()
+
+	return res
+}
@@ -0,0 +1,91 @@
+package greeting_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+type countingMaker struct {
+	inFlight    int64
+	maxInFlight int64
+}
+
+func (c *countingMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	n := atomic.AddInt64(&c.inFlight, 1)
+	defer atomic.AddInt64(&c.inFlight, -1)
+
+	for {
+		m := atomic.LoadInt64(&c.maxInFlight)
+		if n <= m || atomic.CompareAndSwapInt64(&c.maxInFlight, m, n) {
+			break
+		}
+	}
+
+	time.Sleep(time.Millisecond)
+
+	return (&greeting.SimpleMaker{Catalog: catalog}).Hello(ctx, params)
+}
+
+func TestParallelMaker_HelloBatch(t *testing.T) {
+	cm := &countingMaker{}
+	m := &greeting.ParallelMaker{Maker: cm, Concurrency: 3}
+
+	assert.Equal(t, m, m.GreetingBatchMaker())
+
+	var params []greeting.Params
+	for _, name := range []string{"a", "b", "Bug", "c", "d", "e", "f"} {
+		params = append(params, greeting.Params{Name: name, Locale: "en-US"})
+	}
+
+	params = append(params, greeting.Params{Name: "g", Locale: "de-DE"})
+
+	res := m.HelloBatch(context.Background(), params)
+	require.Len(t, res, len(params))
+
+	assert.Equal(t, "Hello, a!", res[0].Greeting.Message)
+	assert.Equal(t, "Hello, f!", res[6].Greeting.Message)
+	assert.NoError(t, res[6].Err)
+	assert.Error(t, res[2].Err)
+	assert.Error(t, res[7].Err)
+	assert.LessOrEqual(t, cm.maxInFlight, int64(3))
+}
+
+type batchMaker struct {
+	countingMaker
+	batches int
+}
+
+func (b *batchMaker) HelloBatch(_ context.Context, params []greeting.Params) []greeting.Result {
+	b.batches++
+
+	return make([]greeting.Result, len(params))
+}
+
+func TestParallelMaker_HelloBatch_upstream(t *testing.T) {
+	bm := &batchMaker{}
+	m := &greeting.ParallelMaker{Maker: bm}
+
+	res := m.HelloBatch(context.Background(), []greeting.Params{{Name: "a"}, {Name: "b"}})
+	assert.Len(t, res, 2)
+	assert.Equal(t, 1, bm.batches)
+}
+
+func TestHelloEach(t *testing.T) {
+	res := greeting.HelloEach(context.Background(), []greeting.Params{{Name: "a"}, {Name: "b"}}, 0,
+		func(_ context.Context, params greeting.Params) (greeting.Greeting, error) {
+			if params.Name == "b" {
+				return greeting.Greeting{}, errors.New("failed")
+			}
+
+			return greeting.Greeting{Message: params.Name}, nil
+		})
+
+	assert.Equal(t, []greeting.Result{{Greeting: greeting.Greeting{Message: "a"}}, {Err: errors.New("failed")}}, res)
+}
@@ -0,0 +1,200 @@
+package greeting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownLocale indicates that locale is missing in catalog.
+var ErrUnknownLocale = errors.New("unknown locale")
+
+var localeCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// Locale describes a supported locale.
+type Locale struct {
+	Code string `json:"-"`
+
+	// Name is a human-readable name of locale.
+	Name string `json:"name"`
+
+	// Fallback is a locale to use if this one has no template,
+	// parent language (pt for pt-BR) is used by default if available.
+	Fallback string `json:"fallback,omitempty"`
+
+	// Template is a default greeting template, it is used if there is no template in database.
+	Template string `json:"template,omitempty"`
+
+	// Default marks locale to use if client has no preference, exactly one locale must be default.
+	Default bool `json:"default,omitempty"`
+}
+
+// Catalog is a set of supported locales.
+type Catalog struct {
+	def     string
+	codes   []string
+	locales map[string]Locale
+	chains  map[string][]string
+}
+
+// LoadCatalog reads locales from JSON files named by locale code, e.g. pt-BR.json.
+func LoadCatalog(fsys fs.FS) (*Catalog, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{
+		locales: make(map[string]Locale, len(files)),
+		chains:  make(map[string][]string, len(files)),
+	}
+
+	for _, f := range files {
+		l := Locale{Code: strings.TrimSuffix(path.Base(f), ".json")}
+
+		if !localeCode.MatchString(l.Code) {
+			return nil, fmt.Errorf("%s: invalid locale code", f)
+		}
+
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &l); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+
+		if l.Template != "" {
+			if err := (Template{Text: l.Template}).Validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+		}
+
+		if l.Default {
+			if c.def != "" {
+				return nil, fmt.Errorf("%s: default locale is already %s", f, c.def)
+			}
+
+			c.def = l.Code
+		}
+
+		c.locales[l.Code] = l
+		c.codes = append(c.codes, l.Code)
+	}
+
+	if len(c.codes) == 0 {
+		return nil, errors.New("empty locale catalog")
+	}
+
+	if c.def == "" {
+		return nil, errors.New("missing default locale in catalog")
+	}
+
+	sort.Strings(c.codes)
+
+	for _, code := range c.codes {
+		if err := c.resolveChain(code); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Catalog) resolveChain(start string) error {
+	var chain []string
+
+	code := start
+	seen := map[string]bool{}
+
+	for code != "" {
+		if seen[code] {
+			return fmt.Errorf("%s: fallback cycle: %s", start, strings.Join(append(chain, code), " → "))
+		}
+
+		l, ok := c.locales[code]
+		if !ok {
+			return fmt.Errorf("%s: unknown fallback %s", start, code)
+		}
+
+		seen[code] = true
+		chain = append(chain, code)
+		code = c.fallback(l)
+	}
+
+	c.chains[start] = chain
+
+	return nil
+}
+
+func (c *Catalog) fallback(l Locale) string {
+	if l.Fallback != "" {
+		return l.Fallback
+	}
+
+	if lang, _, found := strings.Cut(l.Code, "-"); found {
+		if _, ok := c.locales[lang]; ok {
+			return lang
+		}
+	}
+
+	return ""
+}
+
+// Default returns code of default locale.
+func (c *Catalog) Default() string {
+	return c.def
+}
+
+// Codes returns sorted locale codes.
+func (c *Catalog) Codes() []string {
+	return c.codes
+}
+
+// Locale returns locale by code.
+func (c *Catalog) Locale(code string) (Locale, bool) {
+	l, ok := c.locales[code]
+
+	return l, ok
+}
+
+// Chain returns locale code followed by its fallbacks, empty for unknown locale.
+func (c *Catalog) Chain(code string) []string {
+	return c.chains[code]
+}
+
+// Dependents returns locale code and codes of locales that fall back to it.
+func (c *Catalog) Dependents(code string) []string {
+	res := []string{code}
+
+	for _, other := range c.codes {
+		if other == code {
+			continue
+		}
+
+		for _, fb := range c.chains[other][1:] {
+			if fb == code {
+				res = append(res, other)
+
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// LocaleCatalog implements service provider.
+func (c *Catalog) LocaleCatalog() *Catalog {
+	if c == nil {
+		panic("empty Catalog")
+	}
+
+	return c
+}
@@ -0,0 +1,121 @@
+package greeting_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	c, err := greeting.LoadCatalog(fstest.MapFS{
+		"en-US.json": {Data: []byte(`{"name":"English","default":true,"template":"Hello, {name}!"}`)},
+		"pt.json":    {Data: []byte(`{"name":"Português","fallback":"en-US","template":"Olá, {name}!"}`)},
+		"pt-BR.json": {Data: []byte(`{"name":"Português (Brasil)"}`)},
+		"pt-PT.json": {Data: []byte(`{"fallback":"en-US"}`)},
+		"README.md":  {Data: []byte(`Not a locale.`)},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"en-US", "pt", "pt-BR", "pt-PT"}, c.Codes())
+	assert.Equal(t, "en-US", c.Default())
+	assert.Equal(t, []string{"pt-BR", "pt", "en-US"}, c.Chain("pt-BR"))
+	assert.Equal(t, []string{"pt-PT", "en-US"}, c.Chain("pt-PT"))
+	assert.Equal(t, []string{"en-US"}, c.Chain("en-US"))
+	assert.Empty(t, c.Chain("de-DE"))
+
+	assert.Equal(t, []string{"pt", "pt-BR"}, c.Dependents("pt"))
+	assert.Equal(t, []string{"en-US", "pt", "pt-BR", "pt-PT"}, c.Dependents("en-US"))
+	assert.Equal(t, []string{"pt-BR"}, c.Dependents("pt-BR"))
+
+	l, ok := c.Locale("pt")
+	assert.True(t, ok)
+	assert.Equal(t, "Olá, {name}!", l.Template)
+	assert.Equal(t, "pt", l.Code)
+}
+
+func TestLoadCatalog_invalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		files fstest.MapFS
+		err   string
+	}{
+		"empty": {
+			files: fstest.MapFS{},
+			err:   "empty locale catalog",
+		},
+		"no default": {
+			files: fstest.MapFS{"en-US.json": {Data: []byte(`{}`)}},
+			err:   "missing default locale in catalog",
+		},
+		"two defaults": {
+			files: fstest.MapFS{
+				"en-US.json": {Data: []byte(`{"default":true}`)},
+				"ru-RU.json": {Data: []byte(`{"default":true}`)},
+			},
+			err: "ru-RU.json: default locale is already en-US",
+		},
+		"code": {
+			files: fstest.MapFS{"english.json": {Data: []byte(`{}`)}},
+			err:   "english.json: invalid locale code",
+		},
+		"template": {
+			files: fstest.MapFS{"en-US.json": {Data: []byte(`{"template":"Hello!"}`)}},
+			err:   "en-US.json: greeting template must have exactly one {name} placeholder",
+		},
+		"fallback": {
+			files: fstest.MapFS{"ru-RU.json": {Data: []byte(`{"default":true,"fallback":"en-US"}`)}},
+			err:   "ru-RU: unknown fallback en-US",
+		},
+		"cycle": {
+			files: fstest.MapFS{
+				"pt.json":    {Data: []byte(`{"default":true,"fallback":"pt-BR"}`)},
+				"pt-BR.json": {Data: []byte(`{}`)},
+			},
+			err: "pt: fallback cycle: pt → pt-BR → pt",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := greeting.LoadCatalog(tc.files)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestCatalog_Negotiate(t *testing.T) {
+	c, err := greeting.LoadCatalog(fstest.MapFS{
+		"en-US.json": {Data: []byte(`{"default":true}`)},
+		"en-GB.json": {Data: []byte(`{}`)},
+		"pt.json":    {Data: []byte(`{}`)},
+		"pt-BR.json": {Data: []byte(`{}`)},
+		"ru-RU.json": {Data: []byte(`{}`)},
+	})
+	require.NoError(t, err)
+
+	for header, expected := range map[string]string{
+		"":                              "en-US",
+		"ru-RU":                         "ru-RU",
+		"ru":                            "ru-RU",
+		"RU-ru":                         "ru-RU",
+		"pt-BR":                         "pt-BR",
+		"pt-PT":                         "pt",
+		"pt-BR-x-foo":                   "pt-BR",
+		"en":                            "en-US",
+		"en-AU":                         "en-US",
+		"de-DE, ru;q=0.5":               "ru-RU",
+		"ru;q=0.5, pt-BR;q=0.8":         "pt-BR",
+		"ru;q=0.5, pt-BR":               "pt-BR",
+		"ru, pt-BR":                     "ru-RU",
+		"de-DE":                         "en-US",
+		"*":                             "en-US",
+		"de-DE, *;q=0.1":                "en-US",
+		"en;q=0, *":                     "pt",
+		"en-US;q=0, en":                 "en-GB",
+		"ru;q=2, ru;foo=bar, pt-BR;q=x": "en-US",
+		"ru-RU;q=0.5 ,,; q=1, pt":       "pt",
+		"ру-РУ, ru":                     "ru-RU",
+	} {
+		assert.Equal(t, expected, c.Negotiate(header), header)
+	}
+}
@@ -0,0 +1,75 @@
+package greeting
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor indicates malformed pagination cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Stored describes a greeting saved in history.
+type Stored struct {
+	ID        int       `json:"id"`
+	Message   string    `json:"message"`
+	Name      string    `json:"name"`
+	Locale    string    `json:"locale"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ListFilter selects a page of greetings history, newest first.
+type ListFilter struct {
+	Locale        string    `query:"locale" description:"Exact locale."`
+	NamePrefix    string    `query:"name_prefix" description:"Prefix of name."`
+	CreatedAfter  time.Time `query:"created_after" description:"Minimal creation time, inclusive."`
+	CreatedBefore time.Time `query:"created_before" description:"Maximal creation time, exclusive."`
+	Cursor        string    `query:"cursor" description:"Opaque cursor of next page from previous response."`
+	Limit         int       `query:"limit" default:"20" minimum:"1" maximum:"100" description:"Page size."`
+}
+
+// List is a page of greetings history.
+type List struct {
+	Items      []Stored `json:"items"`
+	Total      int      `json:"total" description:"Number of greetings that match filter."`
+	NextCursor string   `json:"nextCursor,omitempty" description:"Cursor of next page, empty on last page."`
+}
+
+// Lister finds greetings history.
+type Lister interface {
+	ListGreetings(ctx context.Context, filter ListFilter) (List, error)
+}
+
+// Finder finds a stored greeting, ErrNotFound is returned if it does not exist.
+type Finder interface {
+	GreetingByID(ctx context.Context, id int) (Stored, error)
+}
+
+// Deleter deletes a stored greeting and returns it, ErrNotFound is returned if it does not exist.
+type Deleter interface {
+	DeleteGreeting(ctx context.Context, id int) (Stored, error)
+}
+
+// Invalidator removes greetings from cache, so that they are built again on next request.
+type Invalidator interface {
+	InvalidateGreeting(ctx context.Context, params Params) error
+	InvalidateLocale(ctx context.Context, locale string) error
+}
+
+// NoOpInvalidator is an Invalidator for a maker without cache.
+type NoOpInvalidator struct{}
+
+// InvalidateGreeting does nothing.
+func (NoOpInvalidator) InvalidateGreeting(context.Context, Params) error {
+	return nil
+}
+
+// InvalidateLocale does nothing.
+func (NoOpInvalidator) InvalidateLocale(context.Context, string) error {
+	return nil
+}
+
+// GreetingInvalidator implements service provider.
+func (n NoOpInvalidator) GreetingInvalidator() Invalidator {
+	return n
+}
@@ -3,20 +3,42 @@ package greeting
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"time"
 
 	"github.com/bool64/ctxd"
 )
 
 // Params describes greeting input.
 type Params struct {
-	Name   string `query:"name" default:"World"`
-	Locale string `query:"locale" required:"true" enum:"en-US,ru-RU"`
+	Name   string `query:"name" json:"name" default:"World"`
+	Locale string `query:"locale" json:"locale,omitempty" catalog:"locale" description:"Locale from catalog, negotiated with Accept-Language if empty."`
+}
+
+// ErrOverloaded indicates that greeting can not be made because of exhausted capacity, caller may retry later.
+var ErrOverloaded = errors.New("greeting capacity exhausted")
+
+// ErrInvalidName indicates that greeting can not be made for a name.
+var ErrInvalidName = errors.New("invalid name")
+
+// ErrNotFound indicates that stored greeting does not exist.
+var ErrNotFound = errors.New("greeting not found")
+
+// Greeting is a result of Maker.
+//
+// It is cached as a value, so it must not have pointers, slices or maps that can be changed by a consumer.
+type Greeting struct {
+	ID              int       `json:"id,omitempty" description:"ID of stored greeting, empty if it is not stored yet."`
+	Message         string    `json:"message"`
+	Locale          string    `json:"locale"`
+	TemplateVersion int       `json:"templateVersion" description:"Version of greeting template, zero for default template of locale catalog."`
+	CreatedAt       time.Time `json:"createdAt"`
 }
 
 // Maker makes a greeting.
 type Maker interface {
-	Hello(ctx context.Context, params Params) (string, error)
+	Hello(ctx context.Context, params Params) (Greeting, error)
 }
 
 // Clearer removes all greetings and returns number of affected rows.
@@ -24,23 +46,36 @@ type Clearer interface {
 	ClearGreetings(ctx context.Context) (int, error)
 }
 
-// SimpleMaker can greet you in two locales.
-type SimpleMaker struct{}
+// RecentFinder finds params of recently made greetings.
+type RecentFinder interface {
+	FindRecentParams(ctx context.Context, limit int) ([]Params, error)
+}
+
+// SimpleMaker greets with default templates of locale catalog.
+type SimpleMaker struct {
+	Catalog *Catalog
+}
 
 // Hello greets.
-func (s *SimpleMaker) Hello(ctx context.Context, params Params) (string, error) {
+func (s *SimpleMaker) Hello(ctx context.Context, params Params) (Greeting, error) {
+	m := TemplateMaker{Templates: noTemplates{}, Catalog: s.Catalog}
+
+	return m.Hello(ctx, params)
+}
+
+type noTemplates struct{}
+
+func (noTemplates) TemplateByLocale(context.Context, string) (Template, error) {
+	return Template{}, ErrTemplateNotFound
+}
+
+// checkBug fails greeting for a special name to demonstrate caching of build errors.
+func checkBug(ctx context.Context, params Params) error {
 	if strings.ToLower(params.Name) == "bug" {
-		return "", ctxd.NewError(ctx, "#$@@^! %C 🤖")
+		return ctxd.LabeledError(ctxd.NewError(ctx, "#$@@^! %C 🤖"), ErrInvalidName)
 	}
 
-	switch params.Locale {
-	case "en-US":
-		return "Hello, " + params.Name + "!", nil
-	case "ru-RU":
-		return "Привет, " + params.Name + "!", nil
-	default:
-		return "", ctxd.NewError(ctx, "unknown locale", "locale", params.Locale)
-	}
+	return nil
 }
 
 // GreetingMaker implements service provider.
@@ -1,14 +1,112 @@
 package greeting_test
 
 import (
+	"context"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/vearutop/cache-story/internal/domain/greeting"
 )
 
+// catalog has locales with default templates.
+var catalog = func() *greeting.Catalog {
+	c, err := greeting.LoadCatalog(fstest.MapFS{
+		"en-US.json": {Data: []byte(`{"default":true,"template":"Hello, {name}!"}`)},
+		"ru-RU.json": {Data: []byte(`{"template":"Привет, {name}!"}`)},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}()
+
 func TestSimpleMaker_GreetingMaker(t *testing.T) {
-	sm := &greeting.SimpleMaker{}
+	sm := &greeting.SimpleMaker{Catalog: catalog}
 
 	assert.Equal(t, sm, sm.GreetingMaker())
 }
+
+func TestSimpleMaker_Hello(t *testing.T) {
+	ctx := context.Background()
+	sm := &greeting.SimpleMaker{Catalog: catalog}
+
+	g, err := sm.Hello(ctx, greeting.Params{Name: "Ann", Locale: "ru-RU"})
+	require.NoError(t, err)
+	assert.Equal(t, "Привет, Ann!", g.Message)
+	assert.Equal(t, "ru-RU", g.Locale)
+
+	_, err = sm.Hello(ctx, greeting.Params{Name: "Ann", Locale: "de-DE"})
+	assert.ErrorIs(t, err, greeting.ErrUnknownLocale)
+
+	_, err = sm.Hello(ctx, greeting.Params{Name: "Bug", Locale: "en-US"})
+	assert.ErrorIs(t, err, greeting.ErrInvalidName)
+}
+
+type templates map[string]string
+
+func (t templates) TemplateByLocale(_ context.Context, locale string) (greeting.Template, error) {
+	text, ok := t[locale]
+	if !ok {
+		return greeting.Template{}, greeting.ErrTemplateNotFound
+	}
+
+	return greeting.Template{Locale: locale, Text: text}, nil
+}
+
+func TestTemplateMaker_Hello(t *testing.T) {
+	ctx := context.Background()
+	m := &greeting.TemplateMaker{Templates: templates{"en-US": "Hi, {name}!"}}
+
+	g, err := m.Hello(ctx, greeting.Params{Name: "Ann", Locale: "en-US"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi, Ann!", g.Message)
+
+	_, err = m.Hello(ctx, greeting.Params{Name: "Ann", Locale: "ru-RU"})
+	assert.ErrorIs(t, err, greeting.ErrTemplateNotFound)
+
+	_, err = m.Hello(ctx, greeting.Params{Name: "Bug", Locale: "en-US"})
+	assert.Error(t, err)
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	assert.NoError(t, greeting.Template{Text: "Hi, {name}!"}.Validate())
+	assert.ErrorIs(t, greeting.Template{Text: "Hi!"}.Validate(), greeting.ErrInvalidTemplate)
+	assert.ErrorIs(t, greeting.Template{Text: "{name}, {name}!"}.Validate(), greeting.ErrInvalidTemplate)
+}
+
+func TestTemplateMaker_Hello_fallback(t *testing.T) {
+	ctx := context.Background()
+	catalog, err := greeting.LoadCatalog(fstest.MapFS{
+		"en-US.json": {Data: []byte(`{"default":true,"template":"Hello, {name}!"}`)},
+		"pt.json":    {Data: []byte(`{"fallback":"en-US","template":"Olá, {name}!"}`)},
+		"pt-BR.json": {Data: []byte(`{}`)},
+		"de-DE.json": {Data: []byte(`{"fallback":"en-US"}`)},
+	})
+	require.NoError(t, err)
+
+	tpl := templates{"en-US": "Hi, {name}!"}
+	m := &greeting.TemplateMaker{Templates: tpl, Catalog: catalog}
+
+	for locale, expected := range map[string]string{
+		"en-US": "Hi, Ann!",  // Stored template.
+		"pt":    "Olá, Ann!", // Default template of catalog.
+		"pt-BR": "Olá, Ann!", // Fallback to pt.
+		"de-DE": "Hi, Ann!",  // Fallback to en-US.
+	} {
+		g, err := m.Hello(ctx, greeting.Params{Name: "Ann", Locale: locale})
+		require.NoError(t, err)
+		assert.Equal(t, expected, g.Message, locale)
+	}
+
+	tpl["pt-BR"] = "Oi, {name}!"
+
+	g, err := m.Hello(ctx, greeting.Params{Name: "Ann", Locale: "pt-BR"})
+	require.NoError(t, err)
+	assert.Equal(t, "Oi, Ann!", g.Message)
+
+	_, err = m.Hello(ctx, greeting.Params{Name: "Ann", Locale: "ru-RU"})
+	assert.ErrorIs(t, err, greeting.ErrUnknownLocale)
+}
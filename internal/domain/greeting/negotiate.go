@@ -0,0 +1,144 @@
+package greeting
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type languageRange struct {
+	tag string
+	q   float64
+}
+
+// Negotiate selects supported locale from Accept-Language header value.
+//
+// Ranges are tried in order of quality, a range matches locale with the same code,
+// then locale of its truncated prefix (pt-BR-x-foo → pt-BR → pt), then any locale of the same language
+// (en → en-US). Wildcard matches default locale. Ranges with zero quality exclude matching locales (en excludes en-US).
+// Default locale is returned if nothing matches.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	ranges, excluded := parseAcceptLanguage(acceptLanguage)
+
+	allowed := func(code string) bool {
+		if code == "" {
+			return false
+		}
+
+		for _, ex := range excluded {
+			if code == ex || strings.HasPrefix(code, ex+"-") {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			for _, code := range append([]string{c.def}, c.codes...) {
+				if allowed(code) {
+					return code
+				}
+			}
+
+			continue
+		}
+
+		if code := c.lookup(r.tag, allowed); code != "" {
+			return code
+		}
+	}
+
+	return c.def
+}
+
+func (c *Catalog) lookup(tag string, allowed func(code string) bool) string {
+	for t := tag; t != ""; {
+		if _, ok := c.locales[t]; ok && allowed(t) {
+			return t
+		}
+
+		i := strings.LastIndex(t, "-")
+		if i < 0 {
+			break
+		}
+
+		t = t[:i]
+	}
+
+	lang, _, _ := strings.Cut(tag, "-")
+
+	for _, code := range append([]string{c.def}, c.codes...) {
+		if strings.HasPrefix(code, lang+"-") && allowed(code) {
+			return code
+		}
+	}
+
+	return ""
+}
+
+// parseAcceptLanguage returns language ranges ordered by quality and excluded ranges.
+//
+// Malformed ranges are ignored.
+func parseAcceptLanguage(header string) (ranges []languageRange, excluded []string) {
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = canonicalTag(strings.TrimSpace(tag))
+
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+
+		if params = strings.TrimSpace(params); params != "" {
+			v, found := strings.CutPrefix(params, "q=")
+			if !found {
+				continue
+			}
+
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		if q == 0 {
+			excluded = append(excluded, tag)
+
+			continue
+		}
+
+		ranges = append(ranges, languageRange{tag: tag, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	return ranges, excluded
+}
+
+// canonicalTag converts language tag to case of catalog codes (pt-br → pt-BR), empty for invalid tag.
+func canonicalTag(tag string) string {
+	if tag == "*" {
+		return tag
+	}
+
+	parts := strings.Split(tag, "-")
+
+	for i, p := range parts {
+		if p == "" || len(p) > 8 || strings.Trim(strings.ToLower(p), "abcdefghijklmnopqrstuvwxyz0123456789") != "" {
+			return ""
+		}
+
+		if i == 1 && len(p) == 2 {
+			parts[i] = strings.ToUpper(p)
+		} else {
+			parts[i] = strings.ToLower(p)
+		}
+	}
+
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,132 @@
+package greeting
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bool64/ctxd"
+)
+
+// NamePlaceholder is replaced with name in greeting template.
+const NamePlaceholder = "{name}"
+
+// ErrTemplateNotFound indicates that greeting template does not exist for locale.
+var ErrTemplateNotFound = errors.New("greeting template not found")
+
+// ErrInvalidTemplate indicates that greeting template can not be used.
+var ErrInvalidTemplate = errors.New("greeting template must have exactly one " + NamePlaceholder + " placeholder")
+
+// Template describes greeting text in a locale.
+type Template struct {
+	Locale    string    `json:"locale"`
+	Text      string    `json:"text" description:"Greeting with {name} placeholder."`
+	Version   int       `json:"version" description:"Incremented on every change."`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate checks template text.
+func (t Template) Validate() error {
+	if strings.Count(t.Text, NamePlaceholder) != 1 {
+		return ErrInvalidTemplate
+	}
+
+	return nil
+}
+
+// Render makes greeting for a name.
+func (t Template) Render(name string) string {
+	return strings.Replace(t.Text, NamePlaceholder, name, 1)
+}
+
+func (t Template) greeting(params Params) Greeting {
+	return Greeting{
+		Message:         t.Render(params.Name),
+		Locale:          params.Locale,
+		TemplateVersion: t.Version,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// TemplateFinder finds greeting template, ErrTemplateNotFound is returned if it does not exist.
+type TemplateFinder interface {
+	TemplateByLocale(ctx context.Context, locale string) (Template, error)
+}
+
+// TemplateStorage manages greeting templates.
+//
+// Stored greetings of a locale are derived from its template and are removed when template changes.
+type TemplateStorage interface {
+	TemplateFinder
+	ListTemplates(ctx context.Context) ([]Template, error)
+	SaveTemplate(ctx context.Context, t Template) (Template, error)
+	DeleteTemplate(ctx context.Context, locale string) (Template, error)
+}
+
+// TemplateInvalidator removes a greeting template from cache.
+type TemplateInvalidator interface {
+	InvalidateTemplate(ctx context.Context, locale string) error
+}
+
+// InvalidateTemplate does nothing.
+func (NoOpInvalidator) InvalidateTemplate(context.Context, string) error {
+	return nil
+}
+
+// TemplateInvalidator implements service provider.
+func (n NoOpInvalidator) TemplateInvalidator() TemplateInvalidator {
+	return n
+}
+
+// TemplateMaker makes greetings from templates.
+//
+// If Catalog is set, locale must be in it and template is resolved along its fallback chain,
+// default template of catalog locale is used if there is no template in storage.
+type TemplateMaker struct {
+	Templates TemplateFinder
+	Catalog   *Catalog
+}
+
+// Hello greets.
+func (m *TemplateMaker) Hello(ctx context.Context, params Params) (Greeting, error) {
+	if err := checkBug(ctx, params); err != nil {
+		return Greeting{}, err
+	}
+
+	chain := []string{params.Locale}
+
+	if m.Catalog != nil {
+		if chain = m.Catalog.Chain(params.Locale); len(chain) == 0 {
+			return Greeting{}, ctxd.WrapError(ctx, ErrUnknownLocale, "failed to make greeting", "locale", params.Locale)
+		}
+	}
+
+	for _, code := range chain {
+		t, err := m.Templates.TemplateByLocale(ctx, code)
+		if err == nil {
+			return t.greeting(params), nil
+		}
+
+		if !errors.Is(err, ErrTemplateNotFound) {
+			return Greeting{}, ctxd.WrapError(ctx, err, "failed to find greeting template", "locale", code)
+		}
+
+		if m.Catalog != nil {
+			if l, _ := m.Catalog.Locale(code); l.Template != "" {
+				return Template{Locale: code, Text: l.Template}.greeting(params), nil
+			}
+		}
+	}
+
+	return Greeting{}, ctxd.WrapError(ctx, ErrTemplateNotFound, "failed to make greeting", "locale", params.Locale)
+}
+
+// GreetingMaker implements service provider.
+func (m *TemplateMaker) GreetingMaker() Maker {
+	if m == nil {
+		panic("empty TemplateMaker")
+	}
+
+	return m
+}
@@ -0,0 +1,101 @@
+package cached
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/stats"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// NewBatchGreetingMaker creates an instance of cached batch greeting maker.
+//
+// Backend must be shared with GreetingMaker to serve the same greetings.
+func NewBatchGreetingMaker(upstream greeting.BatchMaker, backend cache.ReadWriterOf[greeting.Greeting], stats stats.Tracker) *BatchGreetingMaker {
+	return &BatchGreetingMaker{
+		upstream: upstream,
+		backend:  backend,
+		stats:    stats,
+	}
+}
+
+// BatchGreetingMaker reads greetings of a batch from cache and makes missing ones together with upstream.
+//
+// Expired greetings are rebuilt, stale value is served if rebuild fails.
+// Builds are not deduplicated with concurrent builds of GreetingMaker.
+type BatchGreetingMaker struct {
+	upstream greeting.BatchMaker
+	backend  cache.ReadWriterOf[greeting.Greeting]
+	stats    stats.Tracker
+}
+
+// GreetingBatchMaker is a service provider.
+func (b *BatchGreetingMaker) GreetingBatchMaker() greeting.BatchMaker {
+	if b == nil {
+		panic("empty BatchGreetingMaker")
+	}
+
+	return b
+}
+
+// HelloBatch serves greetings.
+func (b *BatchGreetingMaker) HelloBatch(ctx context.Context, params []greeting.Params) []greeting.Result {
+	keys := make([][]byte, len(params))
+	for i, p := range params {
+		keys[i] = greetingKey(p)
+	}
+
+	var (
+		res    = make([]greeting.Result, len(params))
+		misses []greeting.Params
+		items  = make(map[greeting.Params][]int)
+		stale  = make(map[greeting.Params]greeting.Greeting)
+	)
+
+	for i, p := range params {
+		val, err := b.backend.Read(ctx, keys[i])
+		if err == nil {
+			res[i].Greeting = val
+
+			continue
+		}
+
+		var expired cache.ErrWithExpiredItemOf[greeting.Greeting]
+		if errors.As(err, &expired) {
+			stale[p] = expired.Value()
+		}
+
+		// Duplicate params are built once.
+		if _, found := items[p]; !found {
+			misses = append(misses, p)
+		}
+
+		items[p] = append(items[p], i)
+	}
+
+	b.stats.Add(ctx, "batch_items", float64(len(params)), "name", "greetings")
+	b.stats.Add(ctx, "batch_misses", float64(len(misses)), "name", "greetings")
+
+	if len(misses) == 0 {
+		return res
+	}
+
+	for j, r := range b.upstream.HelloBatch(ctx, misses) {
+		p := misses[j]
+
+		if r.Err == nil {
+			_ = b.backend.Write(ctx, greetingKey(p), r.Greeting) //nolint:errcheck // Greeting is served even if it is not cached.
+		} else if val, found := stale[p]; found {
+			b.stats.Add(ctx, "batch_stale_served", 1, "name", "greetings")
+
+			r = greeting.Result{Greeting: val}
+		}
+
+		for _, i := range items[p] {
+			res[i] = r
+		}
+	}
+
+	return res
+}
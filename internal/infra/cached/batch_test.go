@@ -0,0 +1,110 @@
+package cached_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+	"github.com/vearutop/cache-story/internal/infra/locales"
+	"github.com/vearutop/cache-story/internal/infra/replication"
+)
+
+// catalog has locales of application.
+var catalog = func() *greeting.Catalog {
+	c, err := greeting.LoadCatalog(locales.Files)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}()
+
+type recordingBatchMaker struct {
+	mu      sync.Mutex
+	batches [][]greeting.Params
+	fail    bool
+}
+
+func (r *recordingBatchMaker) HelloBatch(ctx context.Context, params []greeting.Params) []greeting.Result {
+	r.mu.Lock()
+	r.batches = append(r.batches, params)
+	r.mu.Unlock()
+
+	if r.fail {
+		res := make([]greeting.Result, len(params))
+		for i := range res {
+			res[i].Err = errors.New("failed")
+		}
+
+		return res
+	}
+
+	return (&greeting.ParallelMaker{Maker: &greeting.SimpleMaker{Catalog: catalog}}).HelloBatch(ctx, params)
+}
+
+func TestBatchGreetingMaker_HelloBatch(t *testing.T) {
+	ctx := context.Background()
+	st := &stats.TrackerMock{}
+	upstream := &recordingBatchMaker{}
+	backend := replication.NewLog(cache.NewShardedMapOf[greeting.Greeting](), time.Minute, 10)
+	bm := cached.NewBatchGreetingMaker(upstream, backend, st)
+
+	assert.Equal(t, bm, bm.GreetingBatchMaker())
+
+	a := greeting.Params{Name: "a", Locale: "en-US"}
+	b := greeting.Params{Name: "b", Locale: "ru-RU"}
+	bug := greeting.Params{Name: "Bug", Locale: "en-US"}
+
+	// Cached by single greeting maker.
+	gm := cached.NewGreetingMaker(&greeting.SimpleMaker{Catalog: catalog}, cache.NewFailoverOf[greeting.Greeting](func(cfg *cache.FailoverConfigOf[greeting.Greeting]) {
+		cfg.Backend = backend
+	}), st)
+	_, err := gm.Hello(ctx, a)
+	require.NoError(t, err)
+
+	res := bm.HelloBatch(ctx, []greeting.Params{a, b, bug, b})
+	require.Len(t, res, 4)
+	assert.Equal(t, "Hello, a!", res[0].Greeting.Message)
+	assert.Equal(t, "Привет, b!", res[1].Greeting.Message)
+	assert.Error(t, res[2].Err)
+	assert.Equal(t, res[1], res[3])
+
+	// Misses are built together and once.
+	assert.Equal(t, [][]greeting.Params{{b, bug}}, upstream.batches)
+	assert.Equal(t, 4, st.Int("batch_items"))
+	assert.Equal(t, 2, st.Int("batch_misses"))
+
+	// Built greetings are cached.
+	res = bm.HelloBatch(ctx, []greeting.Params{b, a})
+	assert.Equal(t, "Привет, b!", res[0].Greeting.Message)
+	assert.Equal(t, "Hello, a!", res[1].Greeting.Message)
+	assert.Len(t, upstream.batches, 1)
+}
+
+func TestBatchGreetingMaker_HelloBatch_stale(t *testing.T) {
+	ctx := context.Background()
+	st := &stats.TrackerMock{}
+	upstream := &recordingBatchMaker{fail: true}
+	backend := replication.NewLog(cache.NewShardedMapOf[greeting.Greeting](), time.Minute, 10)
+	bm := cached.NewBatchGreetingMaker(upstream, backend, st)
+
+	a := greeting.Params{Name: "a", Locale: "en-US"}
+
+	require.NoError(t, backend.Write(cache.WithTTL(ctx, time.Millisecond, false), []byte("en-US:a"), greeting.Greeting{Message: "Hello, a!"}))
+	time.Sleep(2 * time.Millisecond)
+
+	res := bm.HelloBatch(ctx, []greeting.Params{a, {Name: "b", Locale: "en-US"}})
+	assert.NoError(t, res[0].Err)
+	assert.Equal(t, "Hello, a!", res[0].Greeting.Message)
+	assert.Error(t, res[1].Err)
+	assert.Len(t, upstream.batches, 1)
+	assert.Equal(t, 1, st.Int("batch_stale_served"))
+}
@@ -0,0 +1,301 @@
+package cached
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bool64/stats"
+	"github.com/swaggest/usecase/status"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// ErrCircuitOpen indicates that upstream call was rejected by open circuit breaker.
+//
+// It wraps greeting.ErrOverloaded, so cache serves stale value if it has one.
+var ErrCircuitOpen = fmt.Errorf("circuit breaker is open: %w", greeting.ErrOverloaded)
+
+// BreakerConfig controls circuit breaker of greeting builds.
+type BreakerConfig struct {
+	// Enabled turns on circuit breaker.
+	Enabled bool
+
+	// Failures is a number of consecutive failures to open circuit.
+	Failures int `default:"5"`
+
+	// FailureRatio opens circuit if ratio of failures within Window exceeds it, zero disables the check.
+	FailureRatio float64 `split_words:"true" default:"0.5"`
+
+	// MinRequests is a minimal number of requests within Window to check FailureRatio.
+	MinRequests int `split_words:"true" default:"20"`
+
+	// Window is a time interval to count failures for FailureRatio.
+	Window time.Duration `default:"10s"`
+
+	// Cooldown is a time to keep circuit open before probing upstream.
+	Cooldown time.Duration `default:"5s"`
+
+	// Probes is a number of successful requests in half-open state to close circuit.
+	Probes int `default:"1"`
+}
+
+// BreakerState is a state of circuit breaker.
+type BreakerState string
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = BreakerState("closed")
+	BreakerHalfOpen = BreakerState("half-open")
+	BreakerOpen     = BreakerState("open")
+)
+
+// metric returns gauge value of a state.
+func (s BreakerState) metric() float64 {
+	switch s {
+	case BreakerHalfOpen:
+		return 1
+	case BreakerOpen:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// BreakerStatus describes circuit breaker.
+type BreakerStatus struct {
+	State       BreakerState `json:"state"`
+	Since       time.Time    `json:"since"`
+	Failures    int          `json:"consecutiveFailures"`
+	Requests    int          `json:"windowRequests"`
+	WindowFails int          `json:"windowFailures"`
+	OpenedTimes int          `json:"openedTimes"`
+}
+
+// NewCircuitBreaker creates greeting maker that stops calling failing upstream.
+func NewCircuitBreaker(upstream greeting.Maker, cfg BreakerConfig, stats stats.Tracker) *CircuitBreaker {
+	if cfg.Failures <= 0 {
+		cfg.Failures = 5
+	}
+
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+
+	if cfg.Probes <= 0 {
+		cfg.Probes = 1
+	}
+
+	stats.Set(context.Background(), "circuit_breaker_state", BreakerClosed.metric())
+
+	return &CircuitBreaker{
+		upstream: upstream,
+		cfg:      cfg,
+		stats:    stats,
+		state:    BreakerClosed,
+		since:    time.Now(),
+	}
+}
+
+// CircuitBreaker protects unhealthy upstream from misses.
+//
+// Circuit opens after a number of consecutive failures or when failure ratio is too high,
+// open circuit rejects calls with ErrCircuitOpen during cooldown. After cooldown a limited
+// number of probe calls is allowed (half-open state), successful probes close the circuit
+// and a failed probe opens it again.
+type CircuitBreaker struct {
+	upstream greeting.Maker
+	cfg      BreakerConfig
+	stats    stats.Tracker
+
+	mu          sync.Mutex
+	state       BreakerState
+	since       time.Time
+	failures    int
+	windowStart time.Time
+	requests    int
+	windowFails int
+	probes      int
+	probed      int
+	opened      int
+}
+
+// GreetingMaker is a service provider.
+func (b *CircuitBreaker) GreetingMaker() greeting.Maker {
+	if b == nil {
+		panic("empty CircuitBreaker")
+	}
+
+	return b
+}
+
+// Hello makes greeting with upstream unless circuit is open.
+func (b *CircuitBreaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	if err := b.allow(ctx); err != nil {
+		return greeting.Greeting{}, err
+	}
+
+	g, err := b.upstream.Hello(ctx, params)
+
+	// Errors caused by cancelled or timed out request are not upstream failures.
+	b.done(ctx, upstreamFailure(err) && ctx.Err() == nil, err == nil || ctx.Err() == nil)
+
+	return g, err
+}
+
+// requestErrors are caused by request parameters, upstream that returns them is healthy.
+var requestErrors = []error{
+	status.InvalidArgument,
+	status.NotFound,
+	greeting.ErrInvalidName,
+	greeting.ErrUnknownLocale,
+	greeting.ErrTemplateNotFound,
+	greeting.ErrInvalidTemplate,
+	greeting.ErrNotFound,
+}
+
+// upstreamFailure checks if error indicates unhealthy upstream.
+func upstreamFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, re := range requestErrors {
+		if errors.Is(err, re) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allow checks if call can be made and reserves a probe in half-open state.
+func (b *CircuitBreaker) allow(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.since) >= b.cfg.Cooldown {
+		b.transit(ctx, BreakerHalfOpen)
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		b.stats.Add(ctx, "circuit_breaker_rejected", 1)
+
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probes >= b.cfg.Probes {
+			b.stats.Add(ctx, "circuit_breaker_rejected", 1)
+
+			return ErrCircuitOpen
+		}
+
+		b.probes++
+	}
+
+	return nil
+}
+
+// done records result of upstream call.
+func (b *CircuitBreaker) done(ctx context.Context, failed, counted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+
+		switch {
+		case failed:
+			b.transit(ctx, BreakerOpen)
+		case counted:
+			b.probed++
+
+			if b.probed >= b.cfg.Probes {
+				b.transit(ctx, BreakerClosed)
+			}
+		}
+
+		return
+	}
+
+	if !counted || b.state != BreakerClosed {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(b.windowStart) >= b.cfg.Window {
+		b.windowStart = now
+		b.requests = 0
+		b.windowFails = 0
+	}
+
+	b.requests++
+
+	if !failed {
+		b.failures = 0
+
+		return
+	}
+
+	b.failures++
+	b.windowFails++
+
+	if b.failures >= b.cfg.Failures ||
+		(b.cfg.FailureRatio > 0 && b.requests >= b.cfg.MinRequests &&
+			float64(b.windowFails)/float64(b.requests) >= b.cfg.FailureRatio) {
+		b.transit(ctx, BreakerOpen)
+	}
+}
+
+// transit changes state, must be called with lock held.
+func (b *CircuitBreaker) transit(ctx context.Context, state BreakerState) {
+	b.state = state
+	b.since = time.Now()
+	b.failures = 0
+	b.probes = 0
+	b.probed = 0
+	b.requests = 0
+	b.windowFails = 0
+	b.windowStart = b.since
+
+	if state == BreakerOpen {
+		b.opened++
+	}
+
+	b.stats.Set(ctx, "circuit_breaker_state", state.metric())
+	b.stats.Add(ctx, "circuit_breaker_transitions", 1, "state", string(state))
+}
+
+// Status returns current state of circuit breaker.
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	if state == BreakerOpen && time.Since(b.since) >= b.cfg.Cooldown {
+		state = BreakerHalfOpen
+	}
+
+	return BreakerStatus{
+		State:       state,
+		Since:       b.since,
+		Failures:    b.failures,
+		Requests:    b.requests,
+		WindowFails: b.windowFails,
+		OpenedTimes: b.opened,
+	}
+}
+
+// Handler serves circuit breaker status.
+func (b *CircuitBreaker) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(rw).Encode(b.Status()); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
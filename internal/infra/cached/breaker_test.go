@@ -0,0 +1,161 @@
+package cached_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+)
+
+type failingMaker struct {
+	failing bool
+	calls   int
+}
+
+func (f *failingMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	f.calls++
+
+	if f.failing {
+		return greeting.Greeting{}, errors.New("database is down")
+	}
+
+	return (&greeting.SimpleMaker{Catalog: catalog}).Hello(ctx, params)
+}
+
+func TestCircuitBreaker_Hello(t *testing.T) {
+	ctx := context.Background()
+	st := &stats.TrackerMock{}
+	upstream := &failingMaker{failing: true}
+	params := greeting.Params{Name: "a", Locale: "en-US"}
+
+	cb := cached.NewCircuitBreaker(upstream, cached.BreakerConfig{
+		Failures: 3,
+		Cooldown: 20 * time.Millisecond,
+	}, st)
+
+	for i := 0; i < 3; i++ {
+		_, err := cb.Hello(ctx, params)
+		require.EqualError(t, err, "database is down")
+	}
+
+	// Circuit is open, upstream is not called.
+	_, err := cb.Hello(ctx, params)
+	require.ErrorIs(t, err, cached.ErrCircuitOpen)
+	require.ErrorIs(t, err, greeting.ErrOverloaded)
+	assert.Equal(t, 3, upstream.calls)
+	assert.Equal(t, cached.BreakerOpen, cb.Status().State)
+	assert.Equal(t, 1, st.Int("circuit_breaker_rejected"))
+	assert.Equal(t, 2.0, st.Value("circuit_breaker_state"))
+
+	// Failed probe opens circuit again.
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, cached.BreakerHalfOpen, cb.Status().State)
+
+	_, err = cb.Hello(ctx, params)
+	require.EqualError(t, err, "database is down")
+	assert.Equal(t, cached.BreakerOpen, cb.Status().State)
+	assert.Equal(t, 4, upstream.calls)
+
+	// Successful probe closes circuit.
+	upstream.failing = false
+
+	time.Sleep(25 * time.Millisecond)
+
+	g, err := cb.Hello(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, a!", g.Message)
+	assert.Equal(t, cached.BreakerClosed, cb.Status().State)
+	assert.Equal(t, 0.0, st.Value("circuit_breaker_state"))
+	assert.Equal(t, 2, st.Int("circuit_breaker_transitions", "state", "open"))
+}
+
+func TestCircuitBreaker_Hello_requestErrors(t *testing.T) {
+	ctx := context.Background()
+	upstream := &greeting.TemplateMaker{Templates: &templateStore{}}
+
+	cb := cached.NewCircuitBreaker(&greeting.SimpleMaker{Catalog: catalog}, cached.BreakerConfig{Failures: 2}, stats.NoOp{})
+	tb := cached.NewCircuitBreaker(upstream, cached.BreakerConfig{Failures: 2}, stats.NoOp{})
+
+	for i := 0; i < 5; i++ {
+		_, err := cb.Hello(ctx, greeting.Params{Name: "Bug", Locale: "en-US"})
+		require.ErrorIs(t, err, greeting.ErrInvalidName)
+
+		_, err = cb.Hello(ctx, greeting.Params{Name: "a", Locale: "xx-XX"})
+		require.ErrorIs(t, err, greeting.ErrUnknownLocale)
+
+		_, err = tb.Hello(ctx, greeting.Params{Name: "a", Locale: "en-US"})
+		require.ErrorIs(t, err, greeting.ErrTemplateNotFound)
+	}
+
+	// Errors caused by request do not open circuit.
+	assert.Equal(t, cached.BreakerClosed, cb.Status().State)
+	assert.Equal(t, 0, cb.Status().Failures)
+	assert.Equal(t, cached.BreakerClosed, tb.Status().State)
+}
+
+func TestCircuitBreaker_Hello_failureRatio(t *testing.T) {
+	ctx := context.Background()
+	upstream := &failingMaker{}
+	params := greeting.Params{Name: "a", Locale: "en-US"}
+
+	cb := cached.NewCircuitBreaker(upstream, cached.BreakerConfig{
+		Failures:     100,
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Window:       time.Minute,
+		Cooldown:     time.Minute,
+	}, stats.NoOp{})
+
+	for i := 0; i < 4; i++ {
+		upstream.failing = i%2 == 1
+		_, _ = cb.Hello(ctx, params) //nolint:errcheck // Errors are expected.
+	}
+
+	assert.Equal(t, cached.BreakerOpen, cb.Status().State)
+}
+
+func TestCircuitBreaker_Hello_stale(t *testing.T) {
+	ctx := context.Background()
+	upstream := &failingMaker{}
+	params := greeting.Params{Name: "a", Locale: "en-US"}
+
+	cb := cached.NewCircuitBreaker(upstream, cached.BreakerConfig{Failures: 1, Cooldown: time.Minute}, stats.NoOp{})
+
+	fc := cache.NewFailoverOf[greeting.Greeting](func(cfg *cache.FailoverConfigOf[greeting.Greeting]) {
+		cfg.BackendConfig.TimeToLive = time.Millisecond
+		cfg.SyncUpdate = true
+	})
+	gm := cached.NewGreetingMaker(cb, fc, stats.NoOp{})
+
+	g, err := gm.Hello(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, a!", g.Message)
+
+	// Opening circuit with a failure of another key.
+	upstream.failing = true
+	_, err = gm.Hello(ctx, greeting.Params{Name: "b", Locale: "en-US"})
+	require.Error(t, err)
+	assert.Equal(t, cached.BreakerOpen, cb.Status().State)
+
+	time.Sleep(5 * time.Millisecond)
+
+	// Expired value is served while circuit is open.
+	for i := 0; i < 3; i++ {
+		g, err = gm.Hello(ctx, params)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello, a!", g.Message)
+	}
+
+	assert.Equal(t, 2, upstream.calls)
+
+	// Cold key fails fast.
+	_, err = gm.Hello(ctx, greeting.Params{Name: "c", Locale: "en-US"})
+	require.ErrorIs(t, err, cached.ErrCircuitOpen)
+}
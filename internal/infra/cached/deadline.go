@@ -0,0 +1,63 @@
+package cached
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// errNoTime indicates that build was skipped, because request deadline is shorter than a typical build.
+var errNoTime = fmt.Errorf("not enough time to build greeting: %w", context.DeadlineExceeded)
+
+// buildTimer tracks typical build duration as exponentially weighted moving average.
+type buildTimer struct {
+	avg int64 // Nanoseconds.
+}
+
+func (b *buildTimer) observe(d time.Duration) {
+	for {
+		old := atomic.LoadInt64(&b.avg)
+
+		upd := int64(d)
+		if old != 0 {
+			upd = old + (int64(d)-old)/10
+		}
+
+		if atomic.CompareAndSwapInt64(&b.avg, old, upd) {
+			return
+		}
+	}
+}
+
+func (b *buildTimer) typical() time.Duration {
+	return time.Duration(atomic.LoadInt64(&b.avg))
+}
+
+// enoughTime checks if context deadline leaves enough time for a typical build.
+func (b *buildTimer) enoughTime(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+
+	return time.Until(deadline) >= b.typical()
+}
+
+// build calls function if there is enough time and observes duration of successful calls.
+func (b *buildTimer) build(ctx context.Context, f func(ctx context.Context) (greeting.Greeting, error)) (greeting.Greeting, error) {
+	if !b.enoughTime(ctx) {
+		return greeting.Greeting{}, errNoTime
+	}
+
+	start := time.Now()
+
+	v, err := f(ctx)
+	if err == nil {
+		b.observe(time.Since(start))
+	}
+
+	return v, err
+}
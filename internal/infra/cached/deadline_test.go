@@ -0,0 +1,72 @@
+package cached_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+)
+
+type slowMaker struct {
+	delay time.Duration
+	calls int
+}
+
+func (s *slowMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	s.calls++
+	time.Sleep(s.delay)
+
+	return (&greeting.SimpleMaker{Catalog: catalog}).Hello(ctx, params)
+}
+
+func TestGreetingMaker_Hello_deadline(t *testing.T) {
+	upstream := &slowMaker{delay: 20 * time.Millisecond}
+	st := &stats.TrackerMock{}
+
+	fc := cache.NewFailoverOf[greeting.Greeting](func(cfg *cache.FailoverConfigOf[greeting.Greeting]) {
+		cfg.BackendConfig.TimeToLive = 30 * time.Millisecond
+		cfg.SyncUpdate = true
+	})
+
+	makers := map[string]greeting.Maker{
+		"advanced": cached.NewGreetingMaker(upstream, fc, st),
+		"naive":    cached.NewNaiveGreetingMaker(upstream, 30*time.Millisecond, st),
+	}
+
+	for name, gm := range makers {
+		t.Run(name, func(t *testing.T) {
+			upstream.calls = 0
+			params := greeting.Params{Name: name, Locale: "en-US"}
+
+			// Learning typical build duration.
+			g, err := gm.Hello(context.Background(), params)
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, "+name+"!", g.Message)
+
+			short, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+			defer cancel()
+
+			// Cold key fails fast.
+			start := time.Now()
+			_, err = gm.Hello(short, greeting.Params{Name: "cold", Locale: "en-US"})
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Less(t, time.Since(start), 5*time.Millisecond)
+
+			// Stale value is served.
+			time.Sleep(40 * time.Millisecond)
+
+			g, err = gm.Hello(short, params)
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, "+name+"!", g.Message)
+			assert.Equal(t, 1, upstream.calls)
+		})
+	}
+
+	assert.Equal(t, 4, st.Int("build_skipped"))
+}
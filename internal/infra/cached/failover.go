@@ -2,24 +2,33 @@
 package cached
 
 import (
+	"bytes"
 	"context"
+	"errors"
 
 	"github.com/bool64/cache"
+	"github.com/bool64/stats"
 	"github.com/vearutop/cache-story/internal/domain/greeting"
 )
 
 // NewGreetingMaker creates an instance of cached greeting maker.
-func NewGreetingMaker(upstream greeting.Maker, cache *cache.FailoverOf[string]) *GreetingMaker {
+func NewGreetingMaker(upstream greeting.Maker, cache *cache.FailoverOf[greeting.Greeting], stats stats.Tracker) *GreetingMaker {
 	return &GreetingMaker{
 		upstream: upstream,
 		cache:    cache,
+		stats:    stats,
 	}
 }
 
 // GreetingMaker uses cached value if available of fallbacks to upstream.
+//
+// If request deadline is shorter than a typical build, build is skipped
+// and stale value is served if available.
 type GreetingMaker struct {
 	upstream greeting.Maker
-	cache    *cache.FailoverOf[string]
+	cache    *cache.FailoverOf[greeting.Greeting]
+	stats    stats.Tracker
+	builds   buildTimer
 }
 
 // GreetingMaker is a service provider.
@@ -32,10 +41,115 @@ func (g *GreetingMaker) GreetingMaker() greeting.Maker {
 }
 
 // Hello serves greeting.
-func (g *GreetingMaker) Hello(ctx context.Context, params greeting.Params) (string, error) {
-	key := []byte(params.Name + params.Locale)
+func (g *GreetingMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	key := greetingKey(params)
 
-	return g.cache.Get(ctx, key, func(ctx context.Context) (string, error) {
-		return g.upstream.Hello(ctx, params)
+	val, err := g.cache.Get(ctx, key, func(ctx context.Context) (greeting.Greeting, error) {
+		v, err := g.builds.build(ctx, func(ctx context.Context) (greeting.Greeting, error) {
+			return g.upstream.Hello(ctx, params)
+		})
+		if errors.Is(err, errNoTime) {
+			g.stats.Add(ctx, "build_skipped", 1, "name", "greetings")
+		}
+
+		return v, err
 	})
+
+	if errors.Is(err, greeting.ErrOverloaded) || errors.Is(err, errNoTime) {
+		// Overload or short deadline is not a failure of the key, next request should retry the build.
+		_ = g.cache.Errors.Delete(ctx, key) //nolint:errcheck // Error may be already removed.
+
+		// Serving stale value if available.
+		if val.Message != "" {
+			return val, nil
+		}
+	}
+
+	return val, err
+}
+
+// greetingKey starts with locale and a separator, so that greetings of a locale have an exact key prefix,
+// locales of catalog do not contain the separator.
+func greetingKey(params greeting.Params) []byte {
+	return []byte(params.Locale + ":" + params.Name)
+}
+
+// Invalidator removes greetings from GreetingMaker cache.
+type Invalidator struct {
+	// Backend is a backend of failover cache.
+	Backend interface {
+		cache.Deleter
+		cache.WalkerOf[greeting.Greeting]
+	}
+
+	// Errors is a cache of failed builds, optional.
+	Errors *cache.ShardedMapOf[error]
+}
+
+// InvalidateGreeting removes cached greeting and cached build failure.
+func (i *Invalidator) InvalidateGreeting(ctx context.Context, params greeting.Params) error {
+	key := greetingKey(params)
+
+	if i.Errors != nil {
+		if err := i.Errors.Delete(ctx, key); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return err
+		}
+	}
+
+	if err := i.Backend.Delete(ctx, key); err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// InvalidateLocale removes cached greetings and cached build failures of a locale.
+func (i *Invalidator) InvalidateLocale(ctx context.Context, locale string) error {
+	prefix := greetingKey(greeting.Params{Locale: locale})
+	match := func(key []byte) bool { return bytes.HasPrefix(key, prefix) }
+
+	if i.Errors != nil {
+		if err := deleteMatching[error](ctx, i.Errors, match); err != nil {
+			return err
+		}
+	}
+
+	return deleteMatching[greeting.Greeting](ctx, i.Backend, match)
+}
+
+// GreetingInvalidator is a service provider.
+func (i *Invalidator) GreetingInvalidator() greeting.Invalidator {
+	if i == nil {
+		panic("empty Invalidator")
+	}
+
+	return i
+}
+
+// deleteMatching removes entries with matching keys, keys are collected before deletion
+// to avoid changing cache during iteration.
+func deleteMatching[V any](ctx context.Context, c interface {
+	cache.Deleter
+	cache.WalkerOf[V]
+}, match func(key []byte) bool,
+) error {
+	var keys [][]byte
+
+	if _, err := c.Walk(func(e cache.EntryOf[V]) error {
+		if match(e.Key()) {
+			keys = append(keys, e.Key())
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := c.Delete(ctx, key); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
 }
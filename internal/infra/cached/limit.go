@@ -0,0 +1,94 @@
+package cached
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/bool64/stats"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// LimitConfig controls global concurrency limit of greeting builds.
+type LimitConfig struct {
+	// Concurrency limits number of parallel builds, zero disables limit.
+	Concurrency int
+
+	// QueueTimeout limits time to wait for a free slot before rejection.
+	QueueTimeout time.Duration `split_words:"true" default:"100ms"`
+
+	// RetryAfter is suggested to rejected clients.
+	RetryAfter time.Duration `split_words:"true" default:"1s"`
+}
+
+// NewLimitedGreetingMaker creates greeting maker with limited number of parallel builds.
+func NewLimitedGreetingMaker(upstream greeting.Maker, cfg LimitConfig, stats stats.Tracker) *LimitedGreetingMaker {
+	return &LimitedGreetingMaker{
+		upstream: upstream,
+		cfg:      cfg,
+		sem:      make(chan struct{}, cfg.Concurrency),
+		stats:    stats,
+	}
+}
+
+// LimitedGreetingMaker protects upstream from overload by limiting parallel builds.
+//
+// If there is no free slot within LimitConfig.QueueTimeout, greeting.ErrOverloaded is returned,
+// so that cache can serve stale value or client can retry later.
+type LimitedGreetingMaker struct {
+	upstream greeting.Maker
+	cfg      LimitConfig
+	sem      chan struct{}
+	stats    stats.Tracker
+	queued   int64
+}
+
+// GreetingMaker is a service provider.
+func (g *LimitedGreetingMaker) GreetingMaker() greeting.Maker {
+	if g == nil {
+		panic("empty LimitedGreetingMaker")
+	}
+
+	return g
+}
+
+// Hello makes greeting with upstream if there is a free slot.
+func (g *LimitedGreetingMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	select {
+	case g.sem <- struct{}{}:
+	default:
+		if err := g.wait(ctx); err != nil {
+			return greeting.Greeting{}, err
+		}
+	}
+
+	g.stats.Set(ctx, "build_limit_in_flight", float64(len(g.sem)))
+
+	defer func() {
+		<-g.sem
+	}()
+
+	return g.upstream.Hello(ctx, params)
+}
+
+func (g *LimitedGreetingMaker) wait(ctx context.Context) error {
+	g.stats.Set(ctx, "build_limit_queue", float64(atomic.AddInt64(&g.queued, 1)))
+
+	defer func() {
+		g.stats.Set(ctx, "build_limit_queue", float64(atomic.AddInt64(&g.queued, -1)))
+	}()
+
+	timer := time.NewTimer(g.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case g.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		g.stats.Add(ctx, "build_limit_rejected", 1)
+
+		return greeting.ErrOverloaded
+	}
+}
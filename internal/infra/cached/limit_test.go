@@ -0,0 +1,123 @@
+package cached_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+)
+
+type blockingMaker struct {
+	release chan struct{}
+	started chan struct{}
+}
+
+func (b *blockingMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	b.started <- struct{}{}
+	<-b.release
+
+	return (&greeting.SimpleMaker{Catalog: catalog}).Hello(ctx, params)
+}
+
+func TestLimitedGreetingMaker_Hello(t *testing.T) {
+	ctx := context.Background()
+	st := &stats.TrackerMock{}
+	upstream := &blockingMaker{release: make(chan struct{}), started: make(chan struct{}, 10)}
+	lm := cached.NewLimitedGreetingMaker(upstream, cached.LimitConfig{Concurrency: 1, QueueTimeout: 10 * time.Millisecond}, st)
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		g, err := lm.Hello(ctx, greeting.Params{Name: "a", Locale: "en-US"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello, a!", g.Message)
+	}()
+
+	<-upstream.started
+
+	// Slot is busy, caller is rejected after queue timeout.
+	_, err := lm.Hello(ctx, greeting.Params{Name: "b", Locale: "en-US"})
+	require.ErrorIs(t, err, greeting.ErrOverloaded)
+	assert.Equal(t, 1, st.Int("build_limit_rejected"))
+
+	close(upstream.release)
+	wg.Wait()
+
+	g, err := lm.Hello(ctx, greeting.Params{Name: "b", Locale: "en-US"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, b!", g.Message)
+}
+
+type overloadedMaker struct {
+	overloaded bool
+}
+
+func (o *overloadedMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	if o.overloaded {
+		return greeting.Greeting{}, greeting.ErrOverloaded
+	}
+
+	return (&greeting.SimpleMaker{Catalog: catalog}).Hello(ctx, params)
+}
+
+func TestGreetingMaker_Hello_overloaded(t *testing.T) {
+	ctx := context.Background()
+	upstream := &overloadedMaker{}
+	params := greeting.Params{Name: "a", Locale: "en-US"}
+
+	fc := cache.NewFailoverOf[greeting.Greeting](func(cfg *cache.FailoverConfigOf[greeting.Greeting]) {
+		cfg.BackendConfig.TimeToLive = time.Millisecond
+		cfg.SyncUpdate = true
+	})
+	gm := cached.NewGreetingMaker(upstream, fc, stats.NoOp{})
+
+	upstream.overloaded = true
+
+	// No stale value.
+	_, err := gm.Hello(ctx, params)
+	require.ErrorIs(t, err, greeting.ErrOverloaded)
+
+	// Overload is not cached as failure.
+	upstream.overloaded = false
+
+	g, err := gm.Hello(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, a!", g.Message)
+
+	// Stale value is served.
+	time.Sleep(2 * time.Millisecond)
+
+	upstream.overloaded = true
+
+	g, err = gm.Hello(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, a!", g.Message)
+
+	nm := cached.NewNaiveGreetingMaker(upstream, time.Millisecond, stats.NoOp{})
+
+	_, err = nm.Hello(ctx, params)
+	require.ErrorIs(t, err, greeting.ErrOverloaded)
+
+	upstream.overloaded = false
+
+	_, err = nm.Hello(ctx, params)
+	require.NoError(t, err)
+
+	time.Sleep(2 * time.Millisecond)
+
+	upstream.overloaded = true
+
+	g, err = nm.Hello(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, a!", g.Message)
+}
@@ -0,0 +1,204 @@
+package cached
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bool64/stats"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// LoaderConfig controls request-scoped loader of greetings.
+type LoaderConfig struct {
+	// Wait is a time to collect greetings of a batch after the first one.
+	Wait time.Duration `default:"1ms"`
+
+	// MaxBatch dispatches a batch without waiting when it reaches the size.
+	MaxBatch int `split_words:"true" default:"100"`
+}
+
+// NewGreetingLoader creates greeting maker that collects greetings of a request with a loader.
+func NewGreetingLoader(upstream greeting.Maker, batch greeting.BatchMaker, cfg LoaderConfig, stats stats.Tracker) *GreetingLoader {
+	if cfg.Wait <= 0 {
+		cfg.Wait = time.Millisecond
+	}
+
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 100
+	}
+
+	return &GreetingLoader{
+		upstream: upstream,
+		batch:    batch,
+		cfg:      cfg,
+		stats:    stats,
+	}
+}
+
+// GreetingLoader is a greeting maker that uses a loader bound to request context.
+//
+// Loader collects greetings requested within LoaderConfig.Wait, deduplicates them and makes them
+// with a single batch, results are kept until the end of request.
+// Upstream maker is used if there is no loader in context, so loader is enabled per route with Middleware.
+type GreetingLoader struct {
+	upstream greeting.Maker
+	batch    greeting.BatchMaker
+	cfg      LoaderConfig
+	stats    stats.Tracker
+}
+
+type loaderKey struct {
+	g *GreetingLoader
+}
+
+// GreetingMaker is a service provider.
+func (g *GreetingLoader) GreetingMaker() greeting.Maker {
+	if g == nil {
+		panic("empty GreetingLoader")
+	}
+
+	return g
+}
+
+// Middleware binds a loader to request context.
+func (g *GreetingLoader) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(g.WithLoader(r.Context())))
+	})
+}
+
+// WithLoader binds a loader to context.
+//
+// A batch is made with values of its first greeting context, it is canceled when context of loader is done,
+// so that a canceled greeting does not fail others. Failed greetings are not kept and are retried by later calls.
+func (g *GreetingLoader) WithLoader(ctx context.Context) context.Context {
+	l := &loader{
+		g:     g,
+		calls: make(map[greeting.Params]*loaderCall),
+	}
+
+	ctx = context.WithValue(ctx, loaderKey{g: g}, l)
+	l.reqCtx = ctx
+
+	return ctx
+}
+
+// Hello makes greeting with loader from context or with upstream.
+func (g *GreetingLoader) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	if l, ok := ctx.Value(loaderKey{g: g}).(*loader); ok {
+		return l.load(ctx, params)
+	}
+
+	return g.upstream.Hello(ctx, params)
+}
+
+type loader struct {
+	g *GreetingLoader
+
+	reqCtx context.Context //nolint:containedctx // Context that bounds batches.
+
+	mu      sync.Mutex
+	calls   map[greeting.Params]*loaderCall
+	pending []greeting.Params
+	ctx     context.Context //nolint:containedctx // Context of the first pending greeting.
+	timer   *time.Timer
+}
+
+type loaderCall struct {
+	done chan struct{}
+	res  greeting.Result
+}
+
+func (l *loader) load(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	var (
+		full    []greeting.Params
+		fullCtx context.Context
+	)
+
+	l.mu.Lock()
+
+	c, found := l.calls[params]
+	if found {
+		l.g.stats.Add(ctx, "loader_hits", 1, "name", "greetings")
+	} else {
+		c = &loaderCall{done: make(chan struct{})}
+		l.calls[params] = c
+		l.pending = append(l.pending, params)
+
+		if len(l.pending) == 1 {
+			l.ctx = ctx
+		}
+
+		if len(l.pending) >= l.g.cfg.MaxBatch {
+			fullCtx, full = l.take()
+		} else if l.timer == nil {
+			l.timer = time.AfterFunc(l.g.cfg.Wait, l.dispatchPending)
+		}
+	}
+
+	l.mu.Unlock()
+
+	if full != nil {
+		l.dispatch(fullCtx, full)
+	}
+
+	select {
+	case <-c.done:
+		return c.res.Greeting, c.res.Err
+	case <-ctx.Done():
+		return greeting.Greeting{}, ctx.Err()
+	}
+}
+
+// take returns pending params with their context and stops the timer, it must be called with lock.
+func (l *loader) take() (context.Context, []greeting.Params) {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+
+	ctx, pending := l.ctx, l.pending
+	l.ctx, l.pending = nil, nil
+
+	return ctx, pending
+}
+
+func (l *loader) dispatchPending() {
+	l.mu.Lock()
+	ctx, pending := l.take()
+	l.mu.Unlock()
+
+	if len(pending) > 0 {
+		l.dispatch(ctx, pending)
+	}
+}
+
+func (l *loader) dispatch(ctx context.Context, params []greeting.Params) {
+	// Batch is not canceled with its first greeting, but with the loader context.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	stop := context.AfterFunc(l.reqCtx, cancel)
+	defer stop()
+
+	l.g.stats.Add(ctx, "loader_batches", 1, "name", "greetings")
+	l.g.stats.Add(ctx, "loader_items", float64(len(params)), "name", "greetings")
+
+	res := l.g.batch.HelloBatch(ctx, params)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, p := range params {
+		c := l.calls[p]
+		c.res = res[i]
+		close(c.done)
+
+		// Failed call is removed to be retried.
+		if c.res.Err != nil {
+			delete(l.calls, p)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package cached_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+)
+
+func TestGreetingLoader_Hello(t *testing.T) {
+	st := &stats.TrackerMock{}
+	upstream := &failingMaker{}
+	batch := &recordingBatchMaker{}
+	gl := cached.NewGreetingLoader(upstream, batch, cached.LoaderConfig{Wait: 10 * time.Millisecond}, st)
+
+	assert.Equal(t, gl, gl.GreetingMaker())
+
+	a := greeting.Params{Name: "a", Locale: "en-US"}
+	b := greeting.Params{Name: "b", Locale: "ru-RU"}
+
+	ctx := gl.WithLoader(context.Background())
+	wg := sync.WaitGroup{}
+
+	for _, p := range []greeting.Params{a, b, a, {Name: "Bug", Locale: "en-US"}} {
+		p := p
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			g, err := gl.Hello(ctx, p)
+			if p.Name == "Bug" {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, p.Locale, g.Locale)
+		}()
+	}
+
+	wg.Wait()
+
+	// Requests are deduplicated and made with a single batch.
+	require.Len(t, batch.batches, 1)
+	assert.ElementsMatch(t, []greeting.Params{a, b, {Name: "Bug", Locale: "en-US"}}, batch.batches[0])
+	assert.Equal(t, 1, st.Int("loader_hits"))
+
+	// Results are kept in the request.
+	g, err := gl.Hello(ctx, b)
+	require.NoError(t, err)
+	assert.Equal(t, "Привет, b!", g.Message)
+	assert.Len(t, batch.batches, 1)
+	assert.Equal(t, 2, st.Int("loader_hits"))
+
+	// Failed results are not kept.
+	_, err = gl.Hello(ctx, greeting.Params{Name: "Bug", Locale: "en-US"})
+	require.Error(t, err)
+	assert.Len(t, batch.batches, 2)
+	assert.Equal(t, 2, st.Int("loader_hits"))
+
+	// Upstream is used without loader.
+	_, err = gl.Hello(context.Background(), a)
+	require.NoError(t, err)
+	assert.Equal(t, 1, upstream.calls)
+	assert.Len(t, batch.batches, 2)
+}
+
+type ctxBatchMaker struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *ctxBatchMaker) HelloBatch(ctx context.Context, params []greeting.Params) []greeting.Result {
+	close(m.started)
+
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+	}
+
+	res := (&greeting.ParallelMaker{Maker: &greeting.SimpleMaker{Catalog: catalog}}).HelloBatch(ctx, params)
+	for i := range res {
+		if err := ctx.Err(); err != nil {
+			res[i].Err = err
+		}
+	}
+
+	return res
+}
+
+func TestGreetingLoader_Hello_canceled(t *testing.T) {
+	batch := &ctxBatchMaker{started: make(chan struct{}), release: make(chan struct{})}
+	gl := cached.NewGreetingLoader(&greeting.SimpleMaker{Catalog: catalog}, batch, cached.LoaderConfig{}, stats.NoOp{})
+
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	defer cancelReq()
+
+	ctx := gl.WithLoader(reqCtx)
+	first, cancelFirst := context.WithCancel(ctx)
+	p := greeting.Params{Name: "a", Locale: "en-US"}
+
+	res := make(chan error, 1)
+
+	go func() {
+		_, err := gl.Hello(first, p)
+		res <- err
+	}()
+
+	<-batch.started
+
+	// Canceled first greeting does not fail the batch.
+	cancelFirst()
+	assert.ErrorIs(t, <-res, context.Canceled)
+
+	go func() {
+		_, err := gl.Hello(ctx, p)
+		res <- err
+	}()
+
+	close(batch.release)
+	require.NoError(t, <-res)
+
+	// Batch is canceled with the loader context.
+	batch = &ctxBatchMaker{started: make(chan struct{}), release: make(chan struct{})}
+	gl = cached.NewGreetingLoader(&greeting.SimpleMaker{Catalog: catalog}, batch, cached.LoaderConfig{}, stats.NoOp{})
+	ctx = gl.WithLoader(reqCtx)
+
+	go func() {
+		_, err := gl.Hello(context.WithoutCancel(ctx), p)
+		res <- err
+	}()
+
+	<-batch.started
+	cancelReq()
+	assert.ErrorIs(t, <-res, context.Canceled)
+}
+
+func TestGreetingLoader_Hello_maxBatch(t *testing.T) {
+	batch := &recordingBatchMaker{}
+	gl := cached.NewGreetingLoader(&greeting.SimpleMaker{Catalog: catalog}, batch, cached.LoaderConfig{Wait: time.Hour, MaxBatch: 2}, stats.NoOp{})
+
+	ctx := gl.WithLoader(context.Background())
+	wg := sync.WaitGroup{}
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		name := name
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			g, err := gl.Hello(ctx, greeting.Params{Name: name, Locale: "en-US"})
+			assert.NoError(t, err)
+			assert.Equal(t, "Hello, "+name+"!", g.Message)
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, batch.batches, 2)
+}
+
+func TestGreetingLoader_Middleware(t *testing.T) {
+	batch := &recordingBatchMaker{}
+	gl := cached.NewGreetingLoader(&greeting.SimpleMaker{Catalog: catalog}, batch, cached.LoaderConfig{}, stats.NoOp{})
+
+	h := gl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 2; i++ {
+			g, err := gl.Hello(r.Context(), greeting.Params{Name: "a", Locale: "en-US"})
+			assert.NoError(t, err)
+			assert.Equal(t, "Hello, a!", g.Message)
+		}
+	}))
+
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	// Loader is bound to a request.
+	assert.Len(t, batch.batches, 2)
+}
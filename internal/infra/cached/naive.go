@@ -2,6 +2,7 @@ package cached
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -17,10 +18,11 @@ type NaiveGreetingMaker struct {
 	data     map[greeting.Params]greetingEntry
 	upstream greeting.Maker
 	stats    stats.Tracker
+	builds   buildTimer
 }
 
 type greetingEntry struct {
-	value   string
+	value   greeting.Greeting
 	expires time.Time
 }
 
@@ -43,8 +45,16 @@ func (g *NaiveGreetingMaker) GreetingMaker() greeting.Maker {
 	return g
 }
 
+// Len returns number of cached greetings.
+func (g *NaiveGreetingMaker) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return len(g.data)
+}
+
 // Hello makes greeting.
-func (g *NaiveGreetingMaker) Hello(ctx context.Context, params greeting.Params) (string, error) {
+func (g *NaiveGreetingMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
 	g.mu.RLock()
 	val, found := g.data[params]
 	g.mu.RUnlock()
@@ -61,10 +71,20 @@ func (g *NaiveGreetingMaker) Hello(ctx context.Context, params greeting.Params)
 	if !found || expired {
 		g.stats.Add(ctx, cache.MetricWrite, 1, "name", "greetings-naive")
 
-		gr, err := g.upstream.Hello(ctx, params)
+		gr, err := g.builds.build(ctx, func(ctx context.Context) (greeting.Greeting, error) {
+			return g.upstream.Hello(ctx, params)
+		})
 		if err != nil {
 			g.stats.Add(ctx, cache.MetricFailed, 1, "name", "greetings-naive")
 
+			if errors.Is(err, errNoTime) {
+				g.stats.Add(ctx, "build_skipped", 1, "name", "greetings-naive")
+			}
+
+			if expired && (errors.Is(err, greeting.ErrOverloaded) || errors.Is(err, errNoTime)) {
+				return val.value, nil
+			}
+
 			return gr, err
 		}
 
@@ -85,3 +105,40 @@ func (g *NaiveGreetingMaker) Hello(ctx context.Context, params greeting.Params)
 
 	return val.value, nil
 }
+
+// InvalidateGreeting removes cached greeting.
+func (g *NaiveGreetingMaker) InvalidateGreeting(ctx context.Context, params greeting.Params) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.data, params)
+
+	g.stats.Set(ctx, cache.MetricItems, float64(len(g.data)), "name", "greetings-naive")
+
+	return nil
+}
+
+// InvalidateLocale removes cached greetings of a locale.
+func (g *NaiveGreetingMaker) InvalidateLocale(ctx context.Context, locale string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for params := range g.data {
+		if params.Locale == locale {
+			delete(g.data, params)
+		}
+	}
+
+	g.stats.Set(ctx, cache.MetricItems, float64(len(g.data)), "name", "greetings-naive")
+
+	return nil
+}
+
+// GreetingInvalidator is a service provider.
+func (g *NaiveGreetingMaker) GreetingInvalidator() greeting.Invalidator {
+	if g == nil {
+		panic("empty NaiveGreetingMaker")
+	}
+
+	return g
+}
@@ -0,0 +1,58 @@
+package cached
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bool64/cache"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// NewTemplateFinder creates an instance of cached greeting template finder.
+//
+// Backend of failover cache is used to invalidate templates.
+func NewTemplateFinder(upstream greeting.TemplateFinder, cache *cache.FailoverOf[greeting.Template], backend cache.Deleter) *TemplateFinder {
+	return &TemplateFinder{
+		upstream: upstream,
+		cache:    cache,
+		backend:  backend,
+	}
+}
+
+// TemplateFinder uses cached greeting template if available or fallbacks to upstream.
+type TemplateFinder struct {
+	upstream greeting.TemplateFinder
+	cache    *cache.FailoverOf[greeting.Template]
+	backend  cache.Deleter
+}
+
+// TemplateByLocale serves greeting template.
+func (t *TemplateFinder) TemplateByLocale(ctx context.Context, locale string) (greeting.Template, error) {
+	return t.cache.Get(ctx, []byte(locale), func(ctx context.Context) (greeting.Template, error) {
+		return t.upstream.TemplateByLocale(ctx, locale)
+	})
+}
+
+// InvalidateTemplate removes cached template and cached lookup failure.
+func (t *TemplateFinder) InvalidateTemplate(ctx context.Context, locale string) error {
+	key := []byte(locale)
+
+	if err := t.cache.Errors.Delete(ctx, key); err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return err
+	}
+
+	if err := t.backend.Delete(ctx, key); err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// TemplateInvalidator is a service provider.
+func (t *TemplateFinder) TemplateInvalidator() greeting.TemplateInvalidator {
+	if t == nil {
+		panic("empty TemplateFinder")
+	}
+
+	return t
+}
@@ -0,0 +1,151 @@
+package cached_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+)
+
+type templateStore struct {
+	mu    sync.Mutex
+	texts map[string]string
+	calls int
+}
+
+func (s *templateStore) TemplateByLocale(_ context.Context, locale string) (greeting.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+
+	text, ok := s.texts[locale]
+	if !ok {
+		return greeting.Template{}, greeting.ErrTemplateNotFound
+	}
+
+	return greeting.Template{Locale: locale, Text: text}, nil
+}
+
+func (s *templateStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.texts = map[string]string{"en-US": "Hello, {name}!", "ru-RU": "Привет, {name}!"}
+}
+
+func (s *templateStore) set(locale, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.texts[locale] = text
+}
+
+func failoverOf[V any]() (*cache.FailoverOf[V], *cache.ShardedMapOf[V]) {
+	backend := cache.NewShardedMapOf[V]()
+
+	return cache.NewFailoverOf[V](func(cfg *cache.FailoverConfigOf[V]) {
+		cfg.Backend = backend
+	}), backend
+}
+
+func TestTemplateFinder_InvalidateTemplate(t *testing.T) {
+	ctx := context.Background()
+	store := &templateStore{}
+	templatesCache, templatesBackend := failoverOf[greeting.Template]()
+	tf := cached.NewTemplateFinder(store, templatesCache, templatesBackend)
+	upstream := &greeting.TemplateMaker{Templates: tf}
+
+	greetingsCache, greetingsBackend := failoverOf[greeting.Greeting]()
+	naive := cached.NewNaiveGreetingMaker(upstream, time.Hour, stats.NoOp{})
+
+	type maker interface {
+		greeting.Maker
+		greeting.Invalidator
+	}
+
+	makers := map[string]maker{
+		"advanced": struct {
+			greeting.Maker
+			greeting.Invalidator
+		}{
+			Maker:       cached.NewGreetingMaker(upstream, greetingsCache, stats.NoOp{}),
+			Invalidator: &cached.Invalidator{Backend: greetingsBackend, Errors: greetingsCache.Errors},
+		},
+		"naive": naive,
+	}
+
+	for name, gm := range makers {
+		t.Run(name, func(t *testing.T) {
+			store.reset()
+
+			for _, locale := range []string{"en-US", "ru-RU", "de-DE"} {
+				require.NoError(t, tf.InvalidateTemplate(ctx, locale))
+			}
+
+			hello := func(name, locale string) string {
+				t.Helper()
+
+				g, err := gm.Hello(ctx, greeting.Params{Name: name, Locale: locale})
+				require.NoError(t, err)
+
+				return g.Message
+			}
+
+			assert.Equal(t, "Hello, Ann!", hello("Ann", "en-US"))
+			assert.Equal(t, "Hello, Bob!", hello("Bob", "en-US"))
+			assert.Equal(t, "Привет, Ann!", hello("Ann", "ru-RU"))
+
+			// Cached template is used for other names.
+			calls := store.calls
+
+			assert.Equal(t, "Hello, Cid!", hello("Cid", "en-US"))
+			assert.Equal(t, calls, store.calls)
+
+			// Greetings are cached until invalidated.
+			store.set("en-US", "Hi, {name}!")
+			store.set("ru-RU", "Здравствуй, {name}!")
+			assert.Equal(t, "Hello, Ann!", hello("Ann", "en-US"))
+
+			require.NoError(t, tf.InvalidateTemplate(ctx, "en-US"))
+			require.NoError(t, gm.InvalidateLocale(ctx, "en-US"))
+
+			assert.Equal(t, "Hi, Ann!", hello("Ann", "en-US"))
+			assert.Equal(t, "Hi, Bob!", hello("Bob", "en-US"))
+
+			// Other locale is not affected.
+			assert.Equal(t, "Привет, Ann!", hello("Ann", "ru-RU"))
+
+			// Locale that is a suffix of another locale is not confused with it.
+			store.set("US", "Yo, {name}!")
+			assert.Equal(t, "Yo, Ann!", hello("Ann", "US"))
+
+			store.set("en-US", "Hey, {name}!")
+			require.NoError(t, tf.InvalidateTemplate(ctx, "en-US"))
+			require.NoError(t, gm.InvalidateLocale(ctx, "US"))
+
+			assert.Equal(t, "Hi, Ann!", hello("Ann", "en-US"))
+
+			require.NoError(t, gm.InvalidateLocale(ctx, "en-US"))
+			assert.Equal(t, "Hey, Ann!", hello("Ann", "en-US"))
+			assert.Equal(t, "Yo, Ann!", hello("Ann", "US"))
+
+			// Failure of missing template is invalidated too.
+			_, err := gm.Hello(ctx, greeting.Params{Name: "Ann", Locale: "de-DE"})
+			require.ErrorIs(t, err, greeting.ErrTemplateNotFound)
+
+			store.set("de-DE", "Hallo, {name}!")
+			require.NoError(t, tf.InvalidateTemplate(ctx, "de-DE"))
+			require.NoError(t, gm.InvalidateLocale(ctx, "de-DE"))
+
+			assert.Equal(t, "Hallo, Ann!", hello("Ann", "de-DE"))
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package cached
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/stats"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// WarmupConfig controls cache warmup.
+type WarmupConfig struct {
+	// Limit is a number of recent greetings to warm up, zero disables warmup.
+	Limit int
+
+	// Concurrency limits number of parallel builds.
+	Concurrency int `default:"4"`
+
+	// RateLimit limits number of builds per second, zero disables rate limit.
+	RateLimit float64 `split_words:"true" default:"100"`
+}
+
+// Warmup fills cache with recently made greetings.
+type Warmup struct {
+	Config WarmupConfig
+	Finder greeting.RecentFinder
+	Maker  greeting.Maker
+	Logger ctxd.Logger
+	Stats  stats.Tracker
+}
+
+// Importer transfers cache entries from peers and returns used sources.
+type Importer interface {
+	Import(ctx context.Context, extraURLs ...string) ([]string, error)
+}
+
+// Restore imports cache from peers and warms it up if nothing was transferred.
+//
+// It returns source of restored cache ("transfer", "warmup" or empty if cache stays cold),
+// used transfer URLs and error.
+func (w *Warmup) Restore(ctx context.Context, importer Importer, extraURLs ...string) (string, []string, error) {
+	used, err := importer.Import(ctx, extraURLs...)
+	if err != nil {
+		w.Logger.Warn(ctx, "failed to transfer cache", "error", err)
+	}
+
+	if len(used) > 0 {
+		return "transfer", used, nil
+	}
+
+	if w.Config.Limit <= 0 {
+		return "", nil, err
+	}
+
+	if _, err := w.Run(ctx); err != nil {
+		w.Logger.Warn(ctx, "cache warmup failed", "error", err)
+
+		return "warmup", nil, err
+	}
+
+	return "warmup", nil, nil
+}
+
+// Run makes greetings of recent params with Maker and returns number of successful builds.
+func (w *Warmup) Run(ctx context.Context) (int, error) {
+	if w.Config.Limit <= 0 {
+		return 0, nil
+	}
+
+	start := time.Now()
+
+	params, err := w.Finder.FindRecentParams(ctx, w.Config.Limit)
+	if err != nil {
+		return 0, err
+	}
+
+	total := len(params)
+
+	w.Stats.Set(ctx, "warmup_items_planned", float64(total))
+	w.Logger.Important(ctx, "cache warmup started", "total", total)
+
+	var (
+		processed, failed int64
+		wg                sync.WaitGroup
+		jobs              = make(chan greeting.Params)
+	)
+
+	concurrency := w.Config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for p := range jobs {
+				result := "ok"
+
+				if _, err := w.Maker.Hello(ctx, p); err != nil {
+					result = "failed"
+
+					atomic.AddInt64(&failed, 1)
+				}
+
+				w.Stats.Add(ctx, "warmup_items", 1, "result", result)
+
+				n := atomic.AddInt64(&processed, 1)
+				w.Stats.Set(ctx, "warmup_progress", float64(n)/float64(total))
+
+				// Reporting every 10%.
+				if step := int64(total / 10); step > 0 && n%step == 0 {
+					w.Logger.Info(ctx, "cache warmup progress", "processed", n, "total", total)
+				}
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+
+	if w.Config.RateLimit > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / w.Config.RateLimit))
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+feed:
+	for _, p := range params {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				break feed
+			case <-tick:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- p:
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	ok := int(processed - failed)
+
+	w.Stats.Set(ctx, "warmup_seconds", time.Since(start).Seconds())
+	w.Logger.Important(ctx, "cache warmup finished",
+		"processed", processed, "failed", failed, "total", total, "elapsed", time.Since(start).String())
+
+	return ok, ctx.Err()
+}
@@ -0,0 +1,168 @@
+package cached_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+)
+
+type recentFinder struct {
+	limit int
+	err   error
+}
+
+func (f *recentFinder) FindRecentParams(_ context.Context, limit int) ([]greeting.Params, error) {
+	f.limit = limit
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	params := make([]greeting.Params, 0, limit)
+	for i := 0; i < limit; i++ {
+		params = append(params, greeting.Params{Name: "user" + strconv.Itoa(i), Locale: "en-US"})
+	}
+
+	return params, nil
+}
+
+// concurrentMaker tracks maximum number of parallel calls.
+type concurrentMaker struct {
+	mu      sync.Mutex
+	calls   int
+	active  int
+	maxSeen int
+}
+
+func (c *concurrentMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	c.mu.Lock()
+	c.calls++
+	c.active++
+
+	if c.active > c.maxSeen {
+		c.maxSeen = c.active
+	}
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+
+	return (&greeting.SimpleMaker{Catalog: catalog}).Hello(ctx, params)
+}
+
+type staticImporter struct {
+	used []string
+	err  error
+}
+
+func (i staticImporter) Import(_ context.Context, _ ...string) ([]string, error) {
+	return i.used, i.err
+}
+
+func newWarmup(cfg cached.WarmupConfig, finder greeting.RecentFinder, maker greeting.Maker, st stats.Tracker) *cached.Warmup {
+	return &cached.Warmup{Config: cfg, Finder: finder, Maker: maker, Logger: ctxd.NoOpLogger{}, Stats: st}
+}
+
+func TestWarmup_Run(t *testing.T) {
+	st := &stats.TrackerMock{}
+	finder := &recentFinder{}
+	maker := &concurrentMaker{}
+	w := newWarmup(cached.WarmupConfig{Limit: 20, Concurrency: 3}, finder, maker, st)
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 20, n)
+	assert.Equal(t, 20, finder.limit)
+	assert.Equal(t, 20, maker.calls)
+	assert.LessOrEqual(t, maker.maxSeen, 3)
+	assert.Equal(t, 20, st.Int("warmup_items", "result", "ok"))
+}
+
+func TestWarmup_Run_disabled(t *testing.T) {
+	finder := &recentFinder{}
+	maker := &concurrentMaker{}
+	w := newWarmup(cached.WarmupConfig{}, finder, maker, stats.NoOp{})
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, finder.limit)
+	assert.Equal(t, 0, maker.calls)
+}
+
+func TestWarmup_Run_errors(t *testing.T) {
+	st := &stats.TrackerMock{}
+
+	// Failed builds are counted and skipped.
+	w := newWarmup(cached.WarmupConfig{Limit: 3, Concurrency: 1}, &recentFinder{}, &failingMaker{failing: true}, st)
+
+	n, err := w.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 3, st.Int("warmup_items", "result", "failed"))
+
+	// Failure to find params fails warmup.
+	w = newWarmup(cached.WarmupConfig{Limit: 3}, &recentFinder{err: errors.New("failed")}, &concurrentMaker{}, st)
+
+	_, err = w.Run(context.Background())
+	require.Error(t, err)
+
+	// Cancelled warmup stops feeding builds.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	maker := &concurrentMaker{}
+	w = newWarmup(cached.WarmupConfig{Limit: 100, RateLimit: 10}, &recentFinder{}, maker, stats.NoOp{})
+
+	_, err = w.Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, maker.calls, 100)
+}
+
+func TestWarmup_Restore(t *testing.T) {
+	ctx := context.Background()
+
+	// Warmup is skipped if transfer has filled the cache.
+	maker := &concurrentMaker{}
+	w := newWarmup(cached.WarmupConfig{Limit: 10}, &recentFinder{}, maker, stats.NoOp{})
+
+	source, used, err := w.Restore(ctx, staticImporter{used: []string{"http://peer"}})
+	require.NoError(t, err)
+	assert.Equal(t, "transfer", source)
+	assert.Equal(t, []string{"http://peer"}, used)
+	assert.Equal(t, 0, maker.calls)
+
+	// Cache is warmed up if transfer has failed.
+	source, used, err = w.Restore(ctx, staticImporter{err: errors.New("failed")})
+	require.NoError(t, err)
+	assert.Equal(t, "warmup", source)
+	assert.Empty(t, used)
+	assert.Equal(t, 10, maker.calls)
+
+	// Transfer error is returned if warmup is disabled.
+	w = newWarmup(cached.WarmupConfig{}, &recentFinder{}, maker, stats.NoOp{})
+
+	source, _, err = w.Restore(ctx, staticImporter{err: errors.New("failed")})
+	require.Error(t, err)
+	assert.Equal(t, "", source)
+
+	// Warmup error is returned.
+	w = newWarmup(cached.WarmupConfig{Limit: 10}, &recentFinder{err: errors.New("failed")}, maker, stats.NoOp{})
+
+	source, _, err = w.Restore(ctx, staticImporter{})
+	require.Error(t, err)
+	assert.Equal(t, "warmup", source)
+}
@@ -0,0 +1,154 @@
+package chaos_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/chaos"
+	"github.com/vearutop/cache-story/internal/infra/locales"
+	_ "modernc.org/sqlite"
+)
+
+// catalog has locales of application.
+var catalog = func() *greeting.Catalog {
+	c, err := greeting.LoadCatalog(locales.Files)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}()
+
+func TestMaker_Hello(t *testing.T) {
+	ctx := context.Background()
+	st := &stats.TrackerMock{}
+	inj := &chaos.Injector{Stats: st}
+	m := &chaos.Maker{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Injector: inj}
+	params := greeting.Params{Name: "a", Locale: "en-US"}
+
+	g, err := m.Hello(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, a!", g.Message)
+
+	inj.SetFaults(map[string]chaos.Fault{chaos.TargetMaker: {ErrorRate: 1}})
+
+	_, err = m.Hello(ctx, params)
+	require.ErrorIs(t, err, chaos.ErrInjected)
+	assert.Equal(t, 1, st.Int("chaos_faults", "target", "maker", "fault", "error"))
+
+	inj.SetFaults(map[string]chaos.Fault{chaos.TargetMaker: {
+		Latency: chaos.Latency{Mean: chaos.Duration(20 * time.Millisecond)},
+	}})
+
+	start := time.Now()
+	_, err = m.Hello(ctx, params)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	// Timeout hangs until context deadline.
+	inj.SetFaults(map[string]chaos.Fault{chaos.TargetMaker: {TimeoutRate: 1}})
+
+	dctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = m.Hello(dctx, params)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Periodic outage window.
+	inj.SetFaults(map[string]chaos.Fault{chaos.TargetMaker: {
+		Outages: []chaos.Window{{From: time.Now(), Every: chaos.Duration(time.Hour), For: chaos.Duration(time.Minute)}},
+	}})
+
+	_, err = m.Hello(ctx, params)
+	require.ErrorIs(t, err, chaos.ErrOutage)
+
+	inj.SetFaults(map[string]chaos.Fault{chaos.TargetMaker: {
+		Outages: []chaos.Window{{From: time.Now().Add(-time.Hour / 2), Every: chaos.Duration(time.Hour), For: chaos.Duration(time.Minute)}},
+	}})
+
+	_, err = m.Hello(ctx, params)
+	require.NoError(t, err)
+}
+
+func TestConnector(t *testing.T) {
+	ctx := context.Background()
+	inj := &chaos.Injector{}
+
+	conn, err := chaos.OpenConnector("sqlite", ":memory:")
+	require.NoError(t, err)
+
+	db := sql.OpenDB(chaos.Connector(conn, inj))
+	db.SetMaxOpenConns(1)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER, ts DATETIME)")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (?, ?)", 1, time.Now())
+	require.NoError(t, err)
+
+	inj.SetFaults(map[string]chaos.Fault{chaos.TargetStorage: {ErrorRate: 1}})
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (?, ?)", 2, time.Now())
+	require.ErrorIs(t, err, chaos.ErrInjected)
+
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(new(int))
+	require.ErrorIs(t, err, chaos.ErrInjected)
+
+	// Other targets do not affect storage.
+	inj.SetFaults(map[string]chaos.Fault{chaos.TargetMaker: {ErrorRate: 1}})
+
+	var cnt int
+
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(&cnt))
+	assert.Equal(t, 1, cnt)
+}
+
+func TestInjector_Handler(t *testing.T) {
+	inj := &chaos.Injector{Token: "secret"}
+	h := inj.Handler()
+
+	req := httptest.NewRequest(http.MethodPut, "/chaos",
+		strings.NewReader(`{"storage":{"errorRate":0.5,"latency":{"distribution":"lognormal","mean":"10ms","stdDev":"5ms"}}}`))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Empty(t, inj.Faults())
+
+	req = httptest.NewRequest(http.MethodPut, "/chaos",
+		strings.NewReader(`{"storage":{"errorRate":0.5,"latency":{"distribution":"lognormal","mean":"10ms","stdDev":"5ms"}}}`))
+	req.Header.Set("Authorization", "Bearer secret")
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), `"mean":"10ms"`)
+	assert.Equal(t, 0.5, inj.Faults()[chaos.TargetStorage].ErrorRate)
+
+	req = httptest.NewRequest(http.MethodPut, "/chaos", strings.NewReader(`{"cache":{}}`))
+	req.Header.Set("Authorization", "Bearer secret")
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/chaos", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Empty(t, inj.Faults())
+}
@@ -0,0 +1,2 @@
+// Package chaos provides fault injection for resilience experiments.
+package chaos
@@ -0,0 +1,164 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ErrInjected is returned by a fault with error rate.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// ErrOutage is returned during scheduled outage window.
+var ErrOutage = errors.New("chaos: scheduled outage")
+
+// errTimeout is returned by a fault with timeout rate if call context has no deadline.
+var errTimeout = fmt.Errorf("chaos: injected timeout: %w", context.DeadlineExceeded)
+
+// Duration is a time.Duration that is represented as a string in JSON, e.g. "150ms".
+type Duration time.Duration
+
+// MarshalJSON encodes duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes duration from a string or a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		var ns int64
+
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return err
+		}
+
+		*d = Duration(ns)
+
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// Latency distributions.
+const (
+	Fixed       = "fixed"
+	Uniform     = "uniform"
+	Normal      = "normal"
+	LogNormal   = "lognormal"
+	Exponential = "exponential"
+)
+
+// Latency describes injected delay.
+type Latency struct {
+	// Distribution is one of fixed (default), uniform, normal, lognormal, exponential.
+	Distribution string `json:"distribution,omitempty"`
+
+	// Mean is a fixed delay, a mean of normal and exponential distributions or a median of log-normal distribution.
+	Mean Duration `json:"mean,omitempty"`
+
+	// StdDev is a standard deviation of normal distribution, for log-normal distribution it is
+	// a scale of deviation relative to Mean.
+	StdDev Duration `json:"stdDev,omitempty"`
+
+	// Min and Max are bounds of uniform distribution, non-zero Max also caps other distributions.
+	Min Duration `json:"min,omitempty"`
+	Max Duration `json:"max,omitempty"`
+}
+
+func (l Latency) sample() time.Duration {
+	var d float64
+
+	mean := float64(l.Mean)
+
+	switch l.Distribution {
+	case Uniform:
+		d = float64(l.Min) + rand.Float64()*float64(l.Max-l.Min) //nolint:gosec // Weak randomness is fine here.
+	case Normal:
+		d = mean + rand.NormFloat64()*float64(l.StdDev) //nolint:gosec
+	case LogNormal:
+		sigma := 0.0
+		if mean > 0 {
+			sigma = float64(l.StdDev) / mean
+		}
+
+		d = mean * math.Exp(rand.NormFloat64()*sigma) //nolint:gosec
+	case Exponential:
+		d = rand.ExpFloat64() * mean //nolint:gosec
+	default:
+		d = mean
+	}
+
+	if l.Max > 0 && d > float64(l.Max) {
+		d = float64(l.Max)
+	}
+
+	if d < 0 {
+		return 0
+	}
+
+	return time.Duration(d)
+}
+
+// Window is an outage window.
+//
+// Outage is active between From and Until, if both are empty it is always active.
+// With Every, outage repeats for For duration at the beginning of every period since From (or Unix epoch).
+type Window struct {
+	From  time.Time `json:"from,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+	Every Duration  `json:"every,omitempty"`
+	For   Duration  `json:"for,omitempty"`
+}
+
+func (w Window) active(now time.Time) bool {
+	if !w.From.IsZero() && now.Before(w.From) {
+		return false
+	}
+
+	if !w.Until.IsZero() && !now.Before(w.Until) {
+		return false
+	}
+
+	if w.Every <= 0 {
+		return true
+	}
+
+	start := w.From
+	if start.IsZero() {
+		start = time.Unix(0, 0)
+	}
+
+	return now.Sub(start)%time.Duration(w.Every) < time.Duration(w.For)
+}
+
+// Fault describes failures injected into calls.
+type Fault struct {
+	// Latency is added to every call.
+	Latency Latency `json:"latency"`
+
+	// ErrorRate is a probability of failing a call with ErrInjected.
+	ErrorRate float64 `json:"errorRate,omitempty"`
+
+	// TimeoutRate is a probability of hanging a call until its context is done or until Timeout.
+	TimeoutRate float64 `json:"timeoutRate,omitempty"`
+
+	// Timeout limits hanging of a call without deadline, default 10s.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// Outages fail calls with ErrOutage during scheduled windows.
+	Outages []Window `json:"outages,omitempty"`
+}
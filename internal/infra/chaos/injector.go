@@ -0,0 +1,173 @@
+package chaos
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bool64/stats"
+)
+
+// Fault targets.
+const (
+	TargetMaker   = "maker"
+	TargetStorage = "storage"
+)
+
+// Config controls fault injection.
+type Config struct {
+	// Enabled adds fault injection decorators, no faults are active by default.
+	Enabled bool
+
+	// Token is a bearer token to control faults at runtime, control endpoint is disabled without token.
+	Token string
+}
+
+// Injector applies faults of targets to calls.
+type Injector struct {
+	Stats stats.Tracker
+
+	// Token is a bearer token to access Handler, all requests are rejected if it is empty.
+	Token string
+
+	mu     sync.RWMutex
+	faults map[string]Fault
+}
+
+// Faults returns active faults by target.
+func (i *Injector) Faults() map[string]Fault {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	res := make(map[string]Fault, len(i.faults))
+	for t, f := range i.faults {
+		res[t] = f
+	}
+
+	return res
+}
+
+// SetFaults replaces active faults, targets not listed are cleared.
+func (i *Injector) SetFaults(faults map[string]Fault) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.faults = faults
+}
+
+// Inject delays or fails a call according to fault of a target.
+func (i *Injector) Inject(ctx context.Context, target string) error {
+	i.mu.RLock()
+	f, ok := i.faults[target]
+	i.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	for _, w := range f.Outages {
+		if w.active(now) {
+			i.count(ctx, target, "outage")
+
+			return ErrOutage
+		}
+	}
+
+	if d := f.Latency.sample(); d > 0 {
+		i.count(ctx, target, "latency")
+
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	if f.TimeoutRate > 0 && rand.Float64() < f.TimeoutRate { //nolint:gosec // Weak randomness is fine here.
+		i.count(ctx, target, "timeout")
+
+		timeout := time.Duration(f.Timeout)
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+
+		if err := sleep(ctx, timeout); err != nil {
+			return err
+		}
+
+		return errTimeout
+	}
+
+	if f.ErrorRate > 0 && rand.Float64() < f.ErrorRate { //nolint:gosec
+		i.count(ctx, target, "error")
+
+		return ErrInjected
+	}
+
+	return nil
+}
+
+func (i *Injector) count(ctx context.Context, target, fault string) {
+	if i.Stats != nil {
+		i.Stats.Add(ctx, "chaos_faults", 1, "target", target, "fault", fault)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handler serves active faults and controls them with a bearer token.
+//
+// PUT request replaces faults with JSON object of faults by target, DELETE request clears all faults.
+func (i *Injector) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if i.Token == "" || subtle.ConstantTimeCompare([]byte(auth), []byte(i.Token)) != 1 {
+			http.Error(rw, "invalid token", http.StatusUnauthorized)
+
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			faults := map[string]Fault{}
+
+			if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			for t := range faults {
+				if t != TargetMaker && t != TargetStorage {
+					http.Error(rw, "unknown target: "+t, http.StatusBadRequest)
+
+					return
+				}
+			}
+
+			i.SetFaults(faults)
+		case http.MethodDelete:
+			i.SetFaults(nil)
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(rw).Encode(i.Faults()); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package chaos
+
+import (
+	"context"
+
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// Maker injects faults of TargetMaker into upstream greeting maker.
+type Maker struct {
+	Upstream greeting.Maker
+	Injector *Injector
+}
+
+// Hello makes a greeting unless fault is injected.
+func (m *Maker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	if err := m.Injector.Inject(ctx, TargetMaker); err != nil {
+		return greeting.Greeting{}, err
+	}
+
+	return m.Upstream.Hello(ctx, params)
+}
+
+// GreetingMaker is a service provider.
+func (m *Maker) GreetingMaker() greeting.Maker {
+	if m == nil {
+		panic("empty chaos.Maker")
+	}
+
+	return m
+}
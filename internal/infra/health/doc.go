@@ -0,0 +1,2 @@
+// Package health provides liveness and readiness probes.
+package health
@@ -0,0 +1,145 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Cache restore states.
+const (
+	StateRestoring = "restoring"
+	StateRestored  = "restored"
+	StateFailed    = "failed"
+	StateTimeout   = "timeout"
+	StateSkipped   = "skipped"
+)
+
+// Readiness reports application as ready to serve traffic when cache restore is over.
+//
+// Restore is over when it has finished, failed or exceeded Timeout.
+type Readiness struct {
+	// Timeout limits time of not-ready state during cache restore, zero means no limit.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	started  time.Time
+	finished time.Time
+	state    string
+	source   string
+	urls     []string
+	err      error
+	caches   map[string]func() int
+}
+
+// AddCache registers cache items counter to report.
+func (r *Readiness) AddCache(name string, count func() int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.caches == nil {
+		r.caches = make(map[string]func() int)
+	}
+
+	r.caches[name] = count
+}
+
+// Start marks beginning of cache restore.
+func (r *Readiness) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.started = time.Now()
+	r.state = StateRestoring
+}
+
+// Finish marks end of cache restore with source name ("transfer", "warmup" or empty) and its URLs.
+func (r *Readiness) Finish(source string, urls []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finished = time.Now()
+	r.source = source
+	r.urls = urls
+	r.err = err
+
+	switch {
+	case err != nil:
+		r.state = StateFailed
+	case source == "":
+		r.state = StateSkipped
+	default:
+		r.state = StateRestored
+	}
+}
+
+type readinessStatus struct {
+	Ready      bool           `json:"ready"`
+	State      string         `json:"state"`
+	Source     string         `json:"source,omitempty"`
+	SourceURLs []string       `json:"sourceUrls,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Elapsed    string         `json:"elapsed,omitempty"`
+	Caches     map[string]int `json:"caches"`
+}
+
+func (r *Readiness) status() readinessStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := readinessStatus{
+		State:      r.state,
+		Source:     r.source,
+		SourceURLs: r.urls,
+		Caches:     make(map[string]int, len(r.caches)),
+	}
+
+	if s.State == "" {
+		s.State = StateSkipped
+	}
+
+	switch {
+	case !r.finished.IsZero():
+		s.Elapsed = r.finished.Sub(r.started).String()
+	case !r.started.IsZero():
+		s.Elapsed = time.Since(r.started).String()
+	}
+
+	if r.err != nil {
+		s.Error = r.err.Error()
+	}
+
+	if s.State == StateRestoring && r.Timeout > 0 && time.Since(r.started) > r.Timeout {
+		s.State = StateTimeout
+	}
+
+	s.Ready = s.State != StateRestoring
+
+	for name, count := range r.caches {
+		s.Caches[name] = count()
+	}
+
+	return s
+}
+
+// ServeHTTP responds with readiness status, status code is 503 while not ready.
+func (r *Readiness) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	s := r.status()
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if !s.Ready {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(rw).Encode(s) //nolint:errcheck // Nothing to do with failed response.
+}
+
+// Liveness responds with OK while application is running.
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"alive":true}` + "\n")) //nolint:errcheck // Nothing to do with failed response.
+	})
+}
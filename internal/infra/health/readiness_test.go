@@ -0,0 +1,109 @@
+package health_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/infra/health"
+)
+
+type readyz struct {
+	Ready      bool           `json:"ready"`
+	State      string         `json:"state"`
+	Source     string         `json:"source"`
+	SourceURLs []string       `json:"sourceUrls"`
+	Error      string         `json:"error"`
+	Caches     map[string]int `json:"caches"`
+}
+
+func check(t *testing.T, r *health.Readiness) (int, readyz) {
+	t.Helper()
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var s readyz
+
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &s))
+
+	return rw.Code, s
+}
+
+func TestReadiness_ServeHTTP(t *testing.T) {
+	r := &health.Readiness{}
+	r.AddCache("greetings", func() int { return 3 })
+
+	// Ready without restore.
+	code, s := check(t, r)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StateSkipped, s.State)
+	assert.Equal(t, map[string]int{"greetings": 3}, s.Caches)
+
+	r.Start()
+
+	code, s = check(t, r)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, s.Ready)
+	assert.Equal(t, health.StateRestoring, s.State)
+
+	r.Finish("transfer", []string{"http://peer"}, nil)
+
+	code, s = check(t, r)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, s.Ready)
+	assert.Equal(t, health.StateRestored, s.State)
+	assert.Equal(t, "transfer", s.Source)
+	assert.Equal(t, []string{"http://peer"}, s.SourceURLs)
+}
+
+func TestReadiness_ServeHTTP_finish(t *testing.T) {
+	for _, tc := range []struct {
+		source string
+		err    error
+		state  string
+	}{
+		{source: "warmup", state: health.StateRestored},
+		{source: "", state: health.StateSkipped},
+		{source: "warmup", err: errors.New("failed"), state: health.StateFailed},
+	} {
+		r := &health.Readiness{}
+		r.Start()
+		r.Finish(tc.source, nil, tc.err)
+
+		code, s := check(t, r)
+		assert.Equal(t, http.StatusOK, code, tc.state)
+		assert.Equal(t, tc.state, s.State)
+
+		if tc.err != nil {
+			assert.Equal(t, tc.err.Error(), s.Error)
+		}
+	}
+}
+
+func TestReadiness_ServeHTTP_timeout(t *testing.T) {
+	r := &health.Readiness{Timeout: 20 * time.Millisecond}
+	r.Start()
+
+	code, _ := check(t, r)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	time.Sleep(30 * time.Millisecond)
+
+	code, s := check(t, r)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StateTimeout, s.State)
+
+	// Late finish does not make application not ready again.
+	r.Finish("warmup", nil, errors.New("failed"))
+
+	code, s = check(t, r)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, s.Ready)
+	assert.Equal(t, health.StateFailed, s.State)
+}
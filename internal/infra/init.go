@@ -8,15 +8,22 @@ import (
 	"github.com/bool64/brick"
 	"github.com/bool64/brick/database"
 	"github.com/bool64/brick/jaeger"
+	"github.com/bool64/cache"
 	_ "github.com/go-sql-driver/mysql" // MySQL driver.
 	"github.com/swaggest/rest/response/gzip"
 	"github.com/vearutop/cache-story/internal/domain/greeting"
 	"github.com/vearutop/cache-story/internal/infra/cached"
+	"github.com/vearutop/cache-story/internal/infra/chaos"
+	"github.com/vearutop/cache-story/internal/infra/health"
+	"github.com/vearutop/cache-story/internal/infra/locales"
+	"github.com/vearutop/cache-story/internal/infra/nethttp"
+	"github.com/vearutop/cache-story/internal/infra/replication"
 	"github.com/vearutop/cache-story/internal/infra/schema"
 	"github.com/vearutop/cache-story/internal/infra/service"
 	"github.com/vearutop/cache-story/internal/infra/storage"
 	"github.com/vearutop/cache-story/internal/infra/storage/mysql"
 	"github.com/vearutop/cache-story/internal/infra/storage/sqlite"
+	"github.com/vearutop/cache-story/internal/infra/transfer"
 	_ "modernc.org/sqlite" // SQLite3 driver.
 )
 
@@ -41,35 +48,262 @@ func NewServiceLocator(cfg service.Config) (loc *service.Locator, err error) {
 
 	schema.SetupOpenapiCollector(l.OpenAPI)
 
+	catalog, err := greeting.LoadCatalog(locales.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	l.LocaleCatalogProvider = catalog
+	schema.SetupLocaleEnum(l.OpenAPI, catalog.Codes())
+
+	l.Transfer = &transfer.Transfer{
+		Config: cfg.CacheTransfer,
+		Logger: l.CtxdLogger(),
+		Stats:  l.StatsTracker(),
+	}
+
+	l.Replication = &replication.Replicator{
+		Config: cfg.CacheReplication,
+		Guard:  l.Transfer,
+		Logger: l.CtxdLogger(),
+		Stats:  l.StatsTracker(),
+	}
+
+	l.Readiness = &health.Readiness{
+		Timeout: cfg.CacheRestoreTimeout,
+	}
+
 	l.HTTPServerMiddlewares = append(l.HTTPServerMiddlewares, gzip.Middleware)
 
+	if cfg.RequestTimeout > 0 {
+		l.APIMiddlewares = append(l.APIMiddlewares, nethttp.RequestTimeout(cfg.RequestTimeout))
+	}
+
+	if cfg.Chaos.Enabled {
+		l.Chaos = &chaos.Injector{Stats: l.StatsTracker(), Token: cfg.Chaos.Token}
+	}
+
 	if err = setupStorage(l, cfg.Database); err != nil {
 		return nil, err
 	}
 
+	var upstream greeting.Maker = &greeting.TemplateMaker{Templates: setupTemplates(l, cfg), Catalog: catalog}
+
+	if l.Chaos != nil {
+		upstream = &chaos.Maker{Upstream: upstream, Injector: l.Chaos}
+	}
+
+	cost, err := storage.NewCostModel(cfg.BuildCost, l.Storage, l.StatsTracker())
+	if err != nil {
+		return nil, err
+	}
+
 	gs := &storage.GreetingSaver{
-		Upstream: &greeting.SimpleMaker{},
+		Upstream: upstream,
 		Storage:  l.Storage,
 		Stats:    l.StatsTracker(),
+		Cost:     cost,
+
+		BatchConcurrency: cfg.BatchConcurrency,
+	}
+
+	if cfg.InsertBatch.Size > 0 {
+		gs.Writer = storage.NewGreetingWriter(l.Storage, cfg.InsertBatch, l.CtxdLogger(), l.StatsTracker())
+
+		l.OnShutdown("greeting_writer", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+			defer cancel()
+
+			if err := gs.Writer.Close(ctx); err != nil {
+				l.CtxdLogger().Error(ctx, "failed to flush greetings", "error", err)
+			}
+		})
 	}
 
 	l.GreetingMakerProvider = gs
 	l.GreetingClearerProvider = gs
+	l.GreetingListerProvider = gs
+	l.GreetingFinderProvider = gs
+	l.GreetingDeleterProvider = gs
+
+	startGreetingRetention(l, cfg)
+
+	if cfg.BuildLease.Enabled {
+		l.GreetingMakerProvider = &storage.LeasedGreetingMaker{
+			Upstream: gs,
+			Leases:   &storage.BuildLeases{Storage: l.Storage, Owner: storage.NewOwner()},
+			Config:   cfg.BuildLease,
+			Stats:    l.StatsTracker(),
+			Writer:   gs.Writer,
+		}
+	}
+
+	var retryAfter time.Duration
+
+	if cfg.CircuitBreaker.Enabled {
+		l.CircuitBreaker = cached.NewCircuitBreaker(l.GreetingMaker(), cfg.CircuitBreaker, l.StatsTracker())
+		l.GreetingMakerProvider = l.CircuitBreaker
+		retryAfter = cfg.CircuitBreaker.Cooldown
+	}
+
+	if cfg.BuildLimit.Concurrency > 0 {
+		l.GreetingMakerProvider = cached.NewLimitedGreetingMaker(l.GreetingMaker(), cfg.BuildLimit, l.StatsTracker())
+		retryAfter = cfg.BuildLimit.RetryAfter
+	}
+
+	if retryAfter > 0 {
+		l.HTTPServerMiddlewares = append(l.HTTPServerMiddlewares, nethttp.RetryAfter(retryAfter))
+	}
+
+	// Batch is stored with a single insert, unless builds are decorated.
+	builder := &greeting.ParallelMaker{Maker: l.GreetingMaker(), Concurrency: cfg.BatchConcurrency}
+
+	l.GreetingInvalidatorProvider = greeting.NoOpInvalidator{}
 
 	if cfg.Cache == "naive" {
-		l.GreetingMakerProvider = cached.NewNaiveGreetingMaker(l.GreetingMaker(), 3*time.Minute, l.StatsTracker())
+		naive := cached.NewNaiveGreetingMaker(l.GreetingMaker(), 3*time.Minute, l.StatsTracker())
+		l.Readiness.AddCache("greetings-naive", naive.Len)
+		l.GreetingMakerProvider = naive
+		l.GreetingInvalidatorProvider = naive
 	} else if cfg.Cache == "advanced" {
-		greetingsCache := brick.MakeCacheOf[string](l.BaseLocator, "greetings", 3*time.Minute)
-		l.GreetingMakerProvider = cached.NewGreetingMaker(l.GreetingMaker(), greetingsCache)
+		greetingsCache, backend := makeCacheOf[greeting.Greeting](l, cfg, "greetings", 3*time.Minute)
+		l.GreetingMakerProvider = cached.NewGreetingMaker(l.GreetingMaker(), greetingsCache, l.StatsTracker())
+		l.GreetingInvalidatorProvider = &cached.Invalidator{Backend: backend, Errors: greetingsCache.Errors}
+		l.GreetingBatchMakerProvider = cached.NewBatchGreetingMaker(builder, backend, l.StatsTracker())
+	}
+
+	setupGreetingLoader(l, cfg)
+
+	if l.Replication.CachesCount() > 0 {
+		l.OnShutdown("cache_replication_streams", l.Replication.Stop)
+	}
 
-		if err := l.TransferCache(context.Background()); err != nil {
-			l.CtxdLogger().Warn(context.Background(), "failed to transfer cache", "error", err)
+	if cfg.Cache != "none" {
+		if cfg.CacheReplication.PrimaryURL != "" && l.Replication.CachesCount() > 0 {
+			startCacheReplication(l, cfg)
+		} else {
+			startCacheRestore(l, cfg, gs)
 		}
+
+		setupCachePush(l, cfg)
+		startCacheSync(l, cfg)
 	}
 
 	return l, nil
 }
 
+// startGreetingRetention runs scheduled cleanup of old greetings in background.
+func startGreetingRetention(l *service.Locator, cfg service.Config) {
+	if cfg.GreetingRetention.MaxAge <= 0 {
+		return
+	}
+
+	r := &storage.Retention{
+		Storage: l.Storage,
+		Leases:  &storage.BuildLeases{Storage: l.Storage, Owner: storage.NewOwner()},
+		Config:  cfg.GreetingRetention,
+		Logger:  l.CtxdLogger(),
+		Stats:   l.StatsTracker(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l.OnShutdown("greeting_retention", cancel)
+
+	go r.RunSchedule(ctx)
+}
+
+// setupGreetingLoader adds request-scoped loader to greeting maker, loader is enabled on routes with its middleware.
+func setupGreetingLoader(l *service.Locator, cfg service.Config) {
+	batch := l.GreetingBatchMakerProvider
+	if batch == nil {
+		batch = &greeting.ParallelMaker{Maker: l.GreetingMaker(), Concurrency: cfg.BatchConcurrency}
+	}
+
+	l.GreetingLoader = cached.NewGreetingLoader(l.GreetingMaker(), batch.GreetingBatchMaker(), cfg.GreetingLoader, l.StatsTracker())
+	l.GreetingMakerProvider = l.GreetingLoader
+
+	// Items of a batch are requested one by one and loader deduplicates them into batches of upstream.
+	l.GreetingBatchMakerProvider = &greeting.ParallelMaker{Maker: l.GreetingLoader, Concurrency: cfg.GreetingLoader.MaxBatch}
+}
+
+// setupTemplates creates greeting templates storage, templates are cached unless cache is disabled.
+func setupTemplates(l *service.Locator, cfg service.Config) greeting.TemplateFinder {
+	ts := &storage.TemplateStore{Storage: l.Storage, Catalog: l.LocaleCatalog()}
+
+	l.TemplateStorageProvider = ts
+	l.TemplateInvalidatorProvider = greeting.NoOpInvalidator{}
+
+	if cfg.Cache == "none" {
+		return ts
+	}
+
+	templatesCache, backend := makeCacheOf[greeting.Template](l, cfg, "greeting-templates", time.Minute)
+	tf := cached.NewTemplateFinder(ts, templatesCache, backend)
+	l.TemplateInvalidatorProvider = tf
+
+	return tf
+}
+
+// startCacheRestore transfers cache from peers or warms it up in background.
+func startCacheRestore(l *service.Locator, cfg service.Config, finder greeting.RecentFinder) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.OnShutdown("cache_restore", cancel)
+
+	l.Readiness.Start()
+
+	w := &cached.Warmup{
+		Config: cfg.Warmup,
+		Finder: finder,
+		Maker:  l.GreetingMaker(),
+		Logger: l.CtxdLogger(),
+		Stats:  l.StatsTracker(),
+	}
+
+	go func() {
+		l.Readiness.Finish(w.Restore(ctx, l.Transfer, cfg.CacheTransferURL))
+	}()
+}
+
+// startCacheReplication follows primary instance, cache is ready after initial snapshot.
+func startCacheReplication(l *service.Locator, cfg service.Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.OnShutdown("cache_replication", cancel)
+
+	l.Readiness.Start()
+
+	l.Replication.Follow(ctx, func() {
+		l.Readiness.Finish("replication", []string{cfg.CacheReplication.PrimaryURL}, nil)
+	})
+}
+
+// startCacheSync runs periodic anti-entropy with peers in background.
+func startCacheSync(l *service.Locator, cfg service.Config) {
+	if cfg.CacheTransfer.SyncInterval <= 0 || l.Transfer.CachesCount() == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l.OnShutdown("cache_sync", cancel)
+
+	go l.Transfer.RunSync(ctx, cfg.CacheTransferURL)
+}
+
+// setupCachePush sends cache to successor instance during graceful shutdown.
+func setupCachePush(l *service.Locator, cfg service.Config) {
+	if cfg.CacheTransfer.SuccessorURL == "" || l.Transfer.CachesCount() == 0 {
+		return
+	}
+
+	l.OnShutdown("cache_push", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		if err := l.Transfer.Push(ctx); err != nil {
+			l.CtxdLogger().Warn(ctx, "failed to push cache to successor", "error", err)
+		}
+	})
+}
+
 func setupStorage(l *service.Locator, cfg database.Config) error {
 	if cfg.DriverName == "" {
 		cfg.DriverName = "mysql"
@@ -87,6 +321,17 @@ func setupStorage(l *service.Locator, cfg database.Config) error {
 		migrations = mysql.Migrations
 	}
 
+	if l.Chaos != nil {
+		conn, err := chaos.OpenConnector(cfg.DriverName, cfg.DSN)
+		if err != nil {
+			return err
+		}
+
+		l.Storage, err = database.SetupStorage(cfg, l.CtxdLogger(), l.StatsTracker(), chaos.Connector(conn, l.Chaos), migrations)
+
+		return err
+	}
+
 	l.Storage, err = database.SetupStorageDSN(cfg, l.CtxdLogger(), l.StatsTracker(), migrations)
 	if err != nil {
 		return err
@@ -94,3 +339,55 @@ func setupStorage(l *service.Locator, cfg database.Config) error {
 
 	return nil
 }
+
+// makeCacheOf creates an instance of failover cache and adds it to cache transfer and replication,
+// backend is returned to delete entries.
+//
+// Value type is registered for transfer, so that peers with different structure of cached types are incompatible.
+func makeCacheOf[V any](l *service.Locator, cfg service.Config, name string, ttl time.Duration) (*cache.FailoverOf[V], transfer.BackendOf[V]) {
+	cache.GobRegister(*new(V))
+
+	m := cache.NewShardedMapOf[V](func(c *cache.Config) {
+		c.Name = name
+		c.Logger = l.CtxdLogger()
+		c.Stats = l.StatsTracker()
+		c.TimeToLive = ttl
+		c.EvictionStrategy = evictionStrategy(cfg.CacheEviction)
+	})
+
+	var backend transfer.BackendOf[V] = m
+
+	// Changes are only recorded if there are followers, follower records changes applied from primary.
+	if cfg.CacheReplication.Enabled || cfg.CacheReplication.PrimaryURL != "" {
+		log := replication.NewLog(m, ttl, cfg.CacheReplication.BufferSize)
+		l.Replication.AddCache(name, log)
+
+		backend = log
+	}
+
+	// Deleted keys are not restored by cache sync from peers that still have them.
+	backend = transfer.NewTombstonesOf(backend, ttl)
+
+	// Transferred entries are stored through backend, so that they are replicated too.
+	l.Transfer.AddCache(name, transfer.Of[V](backend))
+	l.Readiness.AddCache(name, m.Len)
+
+	// Failover cache is not registered in brick cache transfer, because it mounts export without authentication.
+	return cache.NewFailoverOf[V](func(c *cache.FailoverConfigOf[V]) {
+		c.Name = name
+		c.Logger = l.CtxdLogger()
+		c.Stats = l.StatsTracker()
+		c.Backend = backend
+	}), backend
+}
+
+func evictionStrategy(name string) cache.EvictionStrategy {
+	switch name {
+	case "lru":
+		return cache.EvictLeastRecentlyUsed
+	case "lfu":
+		return cache.EvictLeastFrequentlyUsed
+	default:
+		return cache.EvictMostExpired
+	}
+}
@@ -0,0 +1,11 @@
+// Package locales provides catalog of supported locales.
+package locales
+
+import (
+	"embed"
+)
+
+// Files provide locale definitions, one JSON file per locale.
+//
+//go:embed *.json
+var Files embed.FS
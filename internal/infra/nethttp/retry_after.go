@@ -0,0 +1,37 @@
+package nethttp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfter adds Retry-After header to 503 Service Unavailable responses.
+func RetryAfter(d time.Duration) func(http.Handler) http.Handler {
+	seconds := strconv.Itoa(int((d + time.Second - 1) / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&retryAfterWriter{ResponseWriter: rw, seconds: seconds}, r)
+		})
+	}
+}
+
+type retryAfterWriter struct {
+	http.ResponseWriter
+	seconds string
+}
+
+func (w *retryAfterWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", w.seconds)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *retryAfterWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/bool64/brick"
+	"github.com/swaggest/rest/nethttp"
+	"github.com/vearutop/cache-story/internal/infra/health"
 	"github.com/vearutop/cache-story/internal/infra/nethttp/ui"
 	"github.com/vearutop/cache-story/internal/infra/service"
 	"github.com/vearutop/cache-story/internal/usecase"
@@ -14,8 +16,48 @@ import (
 func NewRouter(deps *service.Locator) http.Handler {
 	r := brick.NewBaseWebService(deps.BaseLocator)
 
-	r.Get("/hello", usecase.HelloWorld(deps))
-	r.Delete("/hello", usecase.Clear(deps))
+	if deps.DebugRouter != nil && deps.Transfer.CachesCount() > 0 {
+		deps.DebugRouter.AddLink("transfer-cache", "Transfer Cache")
+		deps.DebugRouter.Method(http.MethodGet, "/transfer-cache", deps.Transfer.Export())
+		deps.DebugRouter.Method(http.MethodPost, "/transfer-cache", deps.Transfer.Export())
+
+		// Import overwrites cached values, so it is only available with authentication.
+		if deps.Transfer.Config.Secret != "" {
+			deps.DebugRouter.Method(http.MethodPost, "/import-cache", deps.Transfer.Receive())
+		}
+	}
+
+	// Replication stream carries the whole cache, so it is only available with authentication.
+	if deps.DebugRouter != nil && deps.Replication.CachesCount() > 0 && deps.Transfer.Config.Secret != "" {
+		deps.DebugRouter.Method(http.MethodGet, "/replicate-cache", deps.Replication.Export())
+	}
+
+	if deps.DebugRouter != nil && deps.CircuitBreaker != nil {
+		deps.DebugRouter.AddLink("circuit-breaker", "Circuit Breaker")
+		deps.DebugRouter.Method(http.MethodGet, "/circuit-breaker", deps.CircuitBreaker.Handler())
+	}
+
+	if deps.DebugRouter != nil && deps.Chaos != nil && deps.Chaos.Token != "" {
+		deps.DebugRouter.Method(http.MethodGet, "/chaos", deps.Chaos.Handler())
+		deps.DebugRouter.Method(http.MethodPut, "/chaos", deps.Chaos.Handler())
+		deps.DebugRouter.Method(http.MethodDelete, "/chaos", deps.Chaos.Handler())
+	}
+
+	r.Method(http.MethodGet, "/livez", health.Liveness())
+	r.Method(http.MethodGet, "/readyz", deps.Readiness)
+
+	api := r.With(deps.APIMiddlewares...)
+
+	api.With(VaryAcceptLanguage).Method(http.MethodGet, "/hello", nethttp.NewHandler(usecase.HelloWorld(deps)))
+	api.With(VaryAcceptLanguage, deps.GreetingLoader.Middleware).Method(http.MethodPost, "/hello/batch", nethttp.NewHandler(usecase.HelloBatch(deps)))
+	api.Method(http.MethodDelete, "/hello", nethttp.NewHandler(usecase.Clear(deps)))
+	api.Method(http.MethodGet, "/greetings", nethttp.NewHandler(usecase.ListGreetings(deps)))
+	api.Method(http.MethodGet, "/greetings/{id}", nethttp.NewHandler(usecase.GetGreeting(deps)))
+	api.Method(http.MethodDelete, "/greetings/{id}", nethttp.NewHandler(usecase.DeleteGreeting(deps)))
+	api.Method(http.MethodGet, "/greeting-templates", nethttp.NewHandler(usecase.ListTemplates(deps)))
+	api.Method(http.MethodGet, "/greeting-templates/{locale}", nethttp.NewHandler(usecase.GetTemplate(deps)))
+	api.Method(http.MethodPut, "/greeting-templates/{locale}", nethttp.NewHandler(usecase.PutTemplate(deps)))
+	api.Method(http.MethodDelete, "/greeting-templates/{locale}", nethttp.NewHandler(usecase.DeleteTemplate(deps)))
 
 	r.Method(http.MethodGet, "/", ui.Index())
 	r.Mount("/static/", http.StripPrefix("/static", ui.Static))
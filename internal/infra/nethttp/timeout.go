@@ -0,0 +1,19 @@
+package nethttp
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestTimeout sets deadline to request context.
+func RequestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
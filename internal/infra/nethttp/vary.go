@@ -0,0 +1,13 @@
+package nethttp
+
+import "net/http"
+
+// VaryAcceptLanguage adds Vary: Accept-Language header to every response, including errors,
+// so that shared caches do not serve a negotiated locale to another client.
+func VaryAcceptLanguage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("Vary", "Accept-Language")
+
+		next.ServeHTTP(rw, r)
+	})
+}
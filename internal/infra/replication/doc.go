@@ -0,0 +1,2 @@
+// Package replication provides continuous cache replication to a hot-standby follower.
+package replication
@@ -0,0 +1,467 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bool64/cache"
+)
+
+const (
+	opWrite = uint8(iota + 1)
+	opDelete
+	opDeleteAll
+	opSnapshotDone
+	opHeartbeat
+)
+
+var opNames = map[uint8]string{
+	opWrite:     "write",
+	opDelete:    "delete",
+	opDeleteAll: "delete_all",
+}
+
+// header starts replication stream.
+type header struct {
+	Epoch    string
+	Seq      uint64
+	Snapshot bool
+}
+
+// eventOf is a replicated cache change.
+type eventOf[V any] struct {
+	Seq      uint64
+	Op       uint8
+	Key      []byte
+	Value    V
+	ExpireAt int64 // Unix nanoseconds, zero for unlimited.
+	Time     int64 // Unix nanoseconds of change on primary.
+	Pending  int   // Number of events queued for follower, only for heartbeat.
+}
+
+// lockStripes is a number of key locks that order backend changes with their events.
+const lockStripes = 64
+
+// Log is a cache backend that records changes for replication.
+//
+// Every write and delete gets a sequence number, recent changes are kept in a ring buffer
+// so that a reconnected follower can catch up without a full snapshot.
+//
+// Changes of the same key are serialized with a striped lock, so that events are published
+// in the order of backend changes, changes of other keys are not blocked.
+type Log[V any] struct {
+	m    *cache.ShardedMapOf[V]
+	ttl  time.Duration
+	size int
+
+	stripes [lockStripes]sync.Mutex
+
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	recent []eventOf[V] // Ring buffer, head is the oldest event when it is full.
+	head   int
+	subs   map[chan eventOf[V]]struct{}
+}
+
+var (
+	_ cache.ReadWriterOf[any] = &Log[any]{}
+	_ cache.Deleter           = &Log[any]{}
+	_ cache.WalkerOf[any]     = &Log[any]{}
+)
+
+// NewLog creates replication log for a cache backend.
+//
+// TTL is a default time to live of backend, size limits number of recent changes to keep
+// and number of pending changes per follower.
+func NewLog[V any](m *cache.ShardedMapOf[V], ttl time.Duration, size int) *Log[V] {
+	if size <= 0 {
+		size = 1
+	}
+
+	epoch := make([]byte, 8)
+	_, _ = rand.Read(epoch) //nolint:errcheck // Random source does not fail.
+
+	return &Log[V]{
+		m:      m,
+		ttl:    ttl,
+		size:   size,
+		epoch:  hex.EncodeToString(epoch),
+		recent: make([]eventOf[V], 0, min(size, 1024)),
+		subs:   make(map[chan eventOf[V]]struct{}),
+	}
+}
+
+// Read returns cached value.
+func (l *Log[V]) Read(ctx context.Context, key []byte) (V, error) {
+	return l.m.Read(ctx, key)
+}
+
+// Write stores value and records the change.
+func (l *Log[V]) Write(ctx context.Context, key []byte, value V) error {
+	ttl := cache.TTL(ctx)
+	if ttl == cache.DefaultTTL {
+		ttl = l.ttl
+	}
+
+	var expireAt int64
+
+	if ttl > 0 {
+		ctx = cache.WithTTL(ctx, ttl, false)
+		expireAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	return l.write(ctx, key, value, expireAt)
+}
+
+func (l *Log[V]) write(ctx context.Context, key []byte, value V, expireAt int64) error {
+	mu := l.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := l.m.Write(ctx, key, value); err != nil {
+		return err
+	}
+
+	l.publish(eventOf[V]{Op: opWrite, Key: key, Value: value, ExpireAt: expireAt})
+
+	return nil
+}
+
+// writeUnlimited stores value that never expires.
+//
+// Context TTL can not disable expiration, so entry is restored instead of written with default TTL of backend.
+func (l *Log[V]) writeUnlimited(key []byte, value V) error {
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(cache.TraitEntryOf[V]{K: key, V: value}); err != nil {
+		return err
+	}
+
+	mu := l.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := l.m.Restore(&buf); err != nil {
+		return err
+	}
+
+	l.publish(eventOf[V]{Op: opWrite, Key: key, Value: value})
+
+	return nil
+}
+
+// Delete removes value and records the change.
+func (l *Log[V]) Delete(ctx context.Context, key []byte) error {
+	mu := l.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := l.m.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	l.publish(eventOf[V]{Op: opDelete, Key: key})
+
+	return nil
+}
+
+// DeleteAll removes all values and records the change.
+func (l *Log[V]) DeleteAll(ctx context.Context) {
+	for i := range l.stripes {
+		l.stripes[i].Lock()
+	}
+
+	defer func() {
+		for i := range l.stripes {
+			l.stripes[i].Unlock()
+		}
+	}()
+
+	l.m.DeleteAll(ctx)
+	l.publish(eventOf[V]{Op: opDeleteAll})
+}
+
+// Restore reads entries in encoding/gob format and stores them with recorded changes,
+// expired entries are skipped.
+func (l *Log[V]) Restore(r io.Reader) (int, error) {
+	var (
+		ctx = context.Background()
+		dec = gob.NewDecoder(r)
+		n   = 0
+	)
+
+	for {
+		var e cache.TraitEntryOf[V]
+
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+
+			return n, err
+		}
+
+		if err := l.applyEvent(ctx, eventOf[V]{Op: opWrite, Key: e.K, Value: e.V, ExpireAt: e.E}); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+}
+
+// Walk iterates cached entries, changes are not recorded.
+func (l *Log[V]) Walk(cb func(entry cache.EntryOf[V]) error) (int, error) {
+	return l.m.Walk(cb)
+}
+
+// Len returns number of cached entries.
+func (l *Log[V]) Len() int {
+	return l.m.Len()
+}
+
+func (l *Log[V]) stripe(key []byte) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write(key) //nolint:errcheck // Hash never fails to write.
+
+	return &l.stripes[h.Sum32()%lockStripes]
+}
+
+// publish records event and sends it to followers, it must be called with lock of the key held.
+//
+// Slow followers miss events instead of blocking cache writes,
+// they detect a gap by sequence number and ask for resync.
+func (l *Log[V]) publish(e eventOf[V]) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	e.Seq = l.seq
+	e.Time = time.Now().UnixNano()
+
+	if e.Key != nil {
+		key := make([]byte, len(e.Key))
+		copy(key, e.Key)
+		e.Key = key
+	}
+
+	if len(l.recent) < l.size {
+		l.recent = append(l.recent, e)
+	} else {
+		l.recent[l.head] = e
+		l.head = (l.head + 1) % l.size
+	}
+
+	for ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// subscribe registers follower channel and returns stream header with recent events to replay.
+//
+// Recent events are replayed if follower has the same epoch and its sequence is still in the ring buffer,
+// otherwise follower needs a snapshot.
+func (l *Log[V]) subscribe(epoch string, since uint64) (chan eventOf[V], header, []eventOf[V]) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := make(chan eventOf[V], l.size)
+	l.subs[ch] = struct{}{}
+
+	h := header{Epoch: l.epoch, Seq: l.seq}
+
+	n := len(l.recent)
+
+	if epoch != l.epoch || since > l.seq || (n > 0 && since+1 < l.recent[l.head].Seq) ||
+		(n == 0 && since != l.seq) {
+		h.Snapshot = true
+
+		return ch, h, nil
+	}
+
+	var replay []eventOf[V]
+
+	for i := 0; i < n; i++ {
+		if e := l.recent[(l.head+i)%n]; e.Seq > since {
+			replay = append(replay, e)
+		}
+	}
+
+	h.Seq = since
+
+	return ch, h, replay
+}
+
+func (l *Log[V]) unsubscribe(ch chan eventOf[V]) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.subs, ch)
+}
+
+// heartbeat makes an event with last sequence and number of events queued for follower.
+func (l *Log[V]) heartbeat(ch chan eventOf[V]) eventOf[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return eventOf[V]{Op: opHeartbeat, Seq: l.seq, Time: time.Now().UnixNano(), Pending: len(ch)}
+}
+
+// stream writes replication stream to encoder until context is done or encoding fails.
+func (l *Log[V]) stream(ctx context.Context, enc *gob.Encoder, flush func() error, epoch string, since uint64, heartbeat time.Duration) error {
+	ch, h, replay := l.subscribe(epoch, since)
+	defer l.unsubscribe(ch)
+
+	if err := enc.Encode(h); err != nil {
+		return err
+	}
+
+	if h.Snapshot {
+		// Changes that happen during the walk are also queued in channel,
+		// they are applied after snapshot and make the result consistent.
+		if _, err := l.m.Walk(func(e cache.EntryOf[V]) error {
+			return enc.Encode(eventOf[V]{Op: opWrite, Key: e.Key(), Value: e.Value(), ExpireAt: e.ExpireAt().UnixNano()})
+		}); err != nil {
+			return err
+		}
+
+		if err := enc.Encode(eventOf[V]{Op: opSnapshotDone, Seq: h.Seq, Time: time.Now().UnixNano()}); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range replay {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := enc.Encode(l.heartbeat(ch)); err != nil {
+				return err
+			}
+		case e := <-ch:
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+
+			// Batching flushes while there are pending events.
+			if len(ch) > 0 {
+				continue
+			}
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// errGap indicates missed events, follower needs to resync.
+var errGap = errors.New("replication gap")
+
+// apply reads replication stream and applies changes to the log.
+func (l *Log[V]) apply(ctx context.Context, dec *gob.Decoder, st *followState) error {
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+
+	if h.Snapshot {
+		l.DeleteAll(ctx)
+		st.onResync(true)
+	} else if st.epoch != "" {
+		st.onResync(false)
+	}
+
+	st.epoch = h.Epoch
+	st.seq = h.Seq
+	snapshot := h.Snapshot
+
+	for {
+		var e eventOf[V]
+		if err := dec.Decode(&e); err != nil {
+			return err
+		}
+
+		switch {
+		case e.Op == opHeartbeat:
+			// Events that are neither applied nor pending were dropped.
+			if !snapshot && e.Seq > st.seq+uint64(e.Pending) {
+				return errGap
+			}
+
+			st.onHeartbeat(e.Seq)
+
+			continue
+		case e.Op == opSnapshotDone:
+			snapshot = false
+
+			st.onSynced()
+
+			continue
+		case snapshot:
+			// Snapshot entries have no sequence.
+		case e.Seq <= st.seq:
+			// Already applied.
+			continue
+		case e.Seq != st.seq+1:
+			return errGap
+		}
+
+		if err := l.applyEvent(ctx, e); err != nil {
+			return err
+		}
+
+		if !snapshot {
+			st.seq = e.Seq
+			st.onEvent(opNames[e.Op], time.Unix(0, e.Time))
+		}
+	}
+}
+
+func (l *Log[V]) applyEvent(ctx context.Context, e eventOf[V]) error {
+	switch e.Op {
+	case opWrite:
+		if e.ExpireAt == 0 {
+			return l.writeUnlimited(e.Key, e.Value)
+		}
+
+		ttl := time.Until(time.Unix(0, e.ExpireAt))
+		if ttl <= 0 {
+			return nil
+		}
+
+		return l.write(cache.WithTTL(ctx, ttl, false), e.Key, e.Value, e.ExpireAt)
+	case opDelete:
+		if err := l.Delete(ctx, e.Key); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return err
+		}
+	case opDeleteAll:
+		l.DeleteAll(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,336 @@
+package replication_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/infra/replication"
+	"github.com/vearutop/cache-story/internal/infra/transfer"
+)
+
+type instance struct {
+	log   *replication.Log[string]
+	r     *replication.Replicator
+	st    *stats.TrackerMock
+	srv   *httptest.Server
+	pause *pausingHandler
+}
+
+func newInstance(t *testing.T, bufferSize int) *instance {
+	t.Helper()
+
+	i := &instance{st: &stats.TrackerMock{}}
+	i.log = replication.NewLog(cache.NewShardedMapOf[string](), time.Minute, bufferSize)
+	i.r = &replication.Replicator{
+		Config: replication.Config{Heartbeat: 10 * time.Millisecond, RetryDelay: 10 * time.Millisecond},
+		Guard: &transfer.Transfer{Config: transfer.Config{
+			Secret: "s3cr3t",
+			Key:    "000102030405060708090a0b0c0d0e0f",
+		}},
+		Stats: i.st,
+	}
+	i.r.AddCache("greetings", i.log)
+
+	i.pause = &pausingHandler{h: i.r.Export()}
+	i.srv = httptest.NewServer(i.pause)
+	t.Cleanup(i.srv.Close)
+
+	return i
+}
+
+func (i *instance) follow(t *testing.T, primary *instance) <-chan struct{} {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	synced := make(chan struct{})
+
+	i.r.Config.PrimaryURL = primary.srv.URL
+	i.r.Follow(ctx, func() { close(synced) })
+
+	return synced
+}
+
+func (i *instance) requireValue(t *testing.T, key, val string) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		v, err := i.log.Read(context.Background(), []byte(key))
+
+		return err == nil && v == val
+	}, time.Second, 5*time.Millisecond, key)
+}
+
+func (i *instance) requireMissing(t *testing.T, key string) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		_, err := i.log.Read(context.Background(), []byte(key))
+
+		return err != nil
+	}, time.Second, 5*time.Millisecond, key)
+}
+
+func TestReplicator_Follow(t *testing.T) {
+	ctx := context.Background()
+	primary := newInstance(t, 100)
+	follower := newInstance(t, 100)
+
+	for j := 0; j < 10; j++ {
+		require.NoError(t, primary.log.Write(ctx, []byte("key"+strconv.Itoa(j)), "val"+strconv.Itoa(j)))
+	}
+
+	// Stale follower entries are removed by snapshot.
+	require.NoError(t, follower.log.Write(ctx, []byte("stale"), "stale"))
+
+	select {
+	case <-follower.follow(t, primary):
+	case <-time.After(time.Second):
+		require.Fail(t, "snapshot timeout")
+	}
+
+	assert.Equal(t, 10, follower.log.Len())
+	follower.requireValue(t, "key9", "val9")
+	follower.requireMissing(t, "stale")
+
+	// Deltas.
+	require.NoError(t, primary.log.Write(ctx, []byte("key1"), "updated1"))
+	require.NoError(t, primary.log.Write(ctx, []byte("key10"), "val10"))
+	require.NoError(t, primary.log.Delete(ctx, []byte("key2")))
+
+	follower.requireValue(t, "key1", "updated1")
+	follower.requireValue(t, "key10", "val10")
+	follower.requireMissing(t, "key2")
+	assert.Equal(t, 10, follower.log.Len())
+
+	require.Eventually(t, func() bool {
+		return follower.st.Int("replication_events") == 3 && follower.st.Value("replication_lag_events") == 0
+	}, time.Second, 5*time.Millisecond)
+
+	primary.log.DeleteAll(ctx)
+
+	require.Eventually(t, func() bool {
+		return follower.log.Len() == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestReplicator_Follow_gap(t *testing.T) {
+	ctx := context.Background()
+	primary := newInstance(t, 5)
+	follower := newInstance(t, 5)
+
+	<-follower.follow(t, primary)
+
+	// Follower can not receive while stream is paused, primary drops events that do not fit the buffer.
+	primary.pause.Pause()
+
+	for j := 0; j < 100; j++ {
+		require.NoError(t, primary.log.Write(ctx, []byte("key"+strconv.Itoa(j)), "val"+strconv.Itoa(j)))
+	}
+
+	primary.pause.Resume()
+
+	follower.requireValue(t, "key0", "val0")
+	follower.requireValue(t, "key99", "val99")
+
+	require.Eventually(t, func() bool {
+		return follower.log.Len() == 100
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, 1, follower.st.Int("replication_gaps"))
+	assert.Equal(t, 2, follower.st.Int("replication_resyncs"))
+}
+
+func TestReplicator_Follow_reconnect(t *testing.T) {
+	ctx := context.Background()
+	primary := newInstance(t, 100)
+	follower := newInstance(t, 100)
+
+	require.NoError(t, primary.log.Write(ctx, []byte("key0"), "val0"))
+
+	<-follower.follow(t, primary)
+
+	// Stream is broken, missed events are replayed from the buffer on reconnect.
+	primary.srv.CloseClientConnections()
+
+	require.NoError(t, primary.log.Write(ctx, []byte("key1"), "val1"))
+
+	follower.requireValue(t, "key1", "val1")
+	assert.Equal(t, 1, follower.st.Int("replication_resyncs", "name", "greetings", "kind", "snapshot"))
+}
+
+func TestReplicator_Follow_replayWrapped(t *testing.T) {
+	ctx := context.Background()
+	primary := newInstance(t, 5)
+	follower := newInstance(t, 5)
+
+	<-follower.follow(t, primary)
+
+	// Ring buffer is wrapped, follower keeps up with every change.
+	for j := 0; j < 7; j++ {
+		require.NoError(t, primary.log.Write(ctx, []byte("key"+strconv.Itoa(j)), "val"+strconv.Itoa(j)))
+		follower.requireValue(t, "key"+strconv.Itoa(j), "val"+strconv.Itoa(j))
+	}
+
+	primary.srv.CloseClientConnections()
+
+	require.NoError(t, primary.log.Write(ctx, []byte("key6"), "updated6"))
+	require.NoError(t, primary.log.Write(ctx, []byte("key7"), "val7"))
+
+	follower.requireValue(t, "key6", "updated6")
+	follower.requireValue(t, "key7", "val7")
+
+	require.Eventually(t, func() bool {
+		return follower.st.Int("replication_resyncs", "name", "greetings", "kind", "replay") == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, follower.st.Int("replication_resyncs", "name", "greetings", "kind", "snapshot"))
+	assert.Equal(t, 0, follower.st.Int("replication_gaps"))
+}
+
+func TestReplicator_Follow_transfer(t *testing.T) {
+	ctx := context.Background()
+	primary := newInstance(t, 100)
+	follower := newInstance(t, 100)
+
+	<-follower.follow(t, primary)
+
+	// Entries that are transferred to primary are replicated.
+	src := cache.NewShardedMapOf[string]()
+	require.NoError(t, src.Write(ctx, []byte("transferred"), "val"))
+
+	buf := bytes.NewBuffer(nil)
+	_, err := transfer.Of[string](src).Dump(buf, transfer.Filter{})
+	require.NoError(t, err)
+
+	n, err := transfer.Of[string](primary.log).Merge(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	follower.requireValue(t, "transferred", "val")
+}
+
+func TestReplicator_Export_auth(t *testing.T) {
+	primary := newInstance(t, 100)
+
+	resp, err := http.Get(primary.srv.URL + "?name=greetings")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Stream is not served without guard.
+	unguarded := httptest.NewServer((&replication.Replicator{}).Export())
+	defer unguarded.Close()
+
+	resp, err = http.Get(unguarded.URL + "?name=greetings")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Follower with another secret can not connect.
+	follower := newInstance(t, 100)
+	follower.r.Guard = &transfer.Transfer{Config: transfer.Config{Secret: "wrong"}}
+	synced := follower.follow(t, primary)
+
+	select {
+	case <-synced:
+		require.Fail(t, "unexpected sync")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// pausingHandler blocks stream writes while paused.
+type pausingHandler struct {
+	h http.Handler
+
+	mu     sync.Mutex
+	paused chan struct{}
+}
+
+func (p *pausingHandler) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.paused = make(chan struct{})
+}
+
+func (p *pausingHandler) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	close(p.paused)
+	p.paused = nil
+}
+
+func (p *pausingHandler) wait() {
+	p.mu.Lock()
+	paused := p.paused
+	p.mu.Unlock()
+
+	if paused != nil {
+		<-paused
+	}
+}
+
+func (p *pausingHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	p.h.ServeHTTP(pausingWriter{ResponseWriter: rw, p: p}, r)
+}
+
+type pausingWriter struct {
+	http.ResponseWriter
+	p *pausingHandler
+}
+
+func (w pausingWriter) Write(b []byte) (int, error) {
+	w.p.wait()
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w pausingWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func TestReplicator_Stop(t *testing.T) {
+	r := &replication.Replicator{}
+
+	r.Stop()
+	assert.NotPanics(t, r.Stop)
+}
+
+func TestLog_Restore_unlimited(t *testing.T) {
+	primary := newInstance(t, 10)
+	follower := newInstance(t, 10)
+	<-follower.follow(t, primary)
+
+	// Entry without expiration is kept without expiration on primary and follower.
+	var buf bytes.Buffer
+
+	require.NoError(t, gob.NewEncoder(&buf).Encode(cache.TraitEntryOf[string]{K: []byte("k"), V: "v"}))
+
+	n, err := primary.log.Restore(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	follower.requireValue(t, "k", "v")
+
+	for _, i := range []*instance{primary, follower} {
+		_, err := i.log.Walk(func(e cache.EntryOf[string]) error {
+			assert.Equal(t, int64(0), e.ExpireAt().UnixNano())
+
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
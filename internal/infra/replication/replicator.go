@@ -0,0 +1,362 @@
+package replication
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/stats"
+)
+
+// Config controls cache replication.
+type Config struct {
+	// Enabled records cache changes and serves replication stream to followers,
+	// stream is only available with cache transfer secret.
+	Enabled bool
+
+	// PrimaryURL is a replication URL of primary instance, if set the instance follows primary.
+	PrimaryURL string `split_words:"true"`
+
+	// BufferSize limits number of recent changes to replay for reconnected follower
+	// and number of pending changes per follower.
+	BufferSize int `split_words:"true" default:"10000"`
+
+	// Heartbeat is an interval of primary heartbeats that report last sequence to follower.
+	Heartbeat time.Duration `default:"1s"`
+
+	// RetryDelay is a delay before reconnecting to primary after failure.
+	RetryDelay time.Duration `split_words:"true" default:"1s"`
+}
+
+// Cache is a replicated cache.
+type Cache interface {
+	stream(ctx context.Context, enc *gob.Encoder, flush func() error, epoch string, since uint64, heartbeat time.Duration) error
+	apply(ctx context.Context, dec *gob.Decoder, st *followState) error
+}
+
+// Guard authenticates and encrypts replication streams, transfer.Transfer implements it with its secret and key.
+type Guard interface {
+	AuthorizeStream(req *http.Request, name string)
+	AuthenticateStream(req *http.Request, name string) error
+	EncryptStream(w io.Writer) (io.Writer, func() error, error)
+	DecryptStream(r io.Reader) (io.Reader, error)
+}
+
+// Replicator streams cache changes from primary to follower instances.
+//
+// Follower starts with a snapshot of primary cache and then applies changes in order of sequence numbers.
+// If follower detects a gap in sequence, it reconnects and primary replays missed changes
+// from its buffer or sends a new snapshot.
+//
+// Streams are served only with Guard, they carry the whole cache and must be protected as cache transfer.
+type Replicator struct {
+	Config    Config
+	Guard     Guard
+	Logger    ctxd.Logger
+	Stats     stats.Tracker
+	Transport http.RoundTripper
+
+	caches map[string]Cache
+
+	stopOnce  sync.Once
+	closeOnce sync.Once
+	stop      chan struct{}
+}
+
+// AddCache registers cache for replication.
+func (r *Replicator) AddCache(name string, c Cache) {
+	if r.caches == nil {
+		r.caches = make(map[string]Cache)
+	}
+
+	r.caches[name] = c
+}
+
+// CachesCount returns how many caches were added.
+func (r *Replicator) CachesCount() int {
+	return len(r.caches)
+}
+
+// Export creates http handler that serves replication stream of a cache.
+func (r *Replicator) Export() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		name := q.Get("name")
+
+		if r.Guard == nil {
+			http.Error(rw, "cache replication requires authentication", http.StatusForbidden)
+
+			return
+		}
+
+		if err := r.Guard.AuthenticateStream(req, name); err != nil {
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+
+		c, ok := r.caches[name]
+		if !ok {
+			http.Error(rw, "cache not found for "+name, http.StatusNotFound)
+
+			return
+		}
+
+		var since uint64
+
+		if s := q.Get("since"); s != "" {
+			var err error
+
+			if since, err = strconv.ParseUint(s, 10, 64); err != nil {
+				http.Error(rw, "invalid since: "+err.Error(), http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "streaming is not supported", http.StatusInternalServerError)
+
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/octet-stream")
+
+		w, flush, err := r.Guard.EncryptStream(rw)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-r.stopped():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		r.logger().Important(ctx, "cache replication follower connected",
+			"name", name, "remote", req.RemoteAddr, "since", since)
+
+		err = c.stream(ctx, gob.NewEncoder(w), func() error {
+			if err := flush(); err != nil {
+				return err
+			}
+
+			flusher.Flush()
+
+			return nil
+		}, q.Get("epoch"), since, r.heartbeat())
+
+		r.logger().Important(ctx, "cache replication follower disconnected",
+			"name", name, "remote", req.RemoteAddr, "error", err)
+	})
+}
+
+// Stop closes replication streams of followers, so that they do not block graceful shutdown.
+func (r *Replicator) Stop() {
+	r.stopped()
+	r.closeOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+func (r *Replicator) stopped() <-chan struct{} {
+	r.stopOnce.Do(func() {
+		r.stop = make(chan struct{})
+	})
+
+	return r.stop
+}
+
+// Follow replicates caches from Config.PrimaryURL in background until context is done.
+//
+// Synced is called once all caches have received initial snapshot.
+func (r *Replicator) Follow(ctx context.Context, synced func()) {
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(names))
+
+	for _, name := range names {
+		st := &followState{r: r, ctx: ctx, name: name, synced: wg.Done}
+
+		go r.follow(ctx, name, st)
+	}
+
+	if synced != nil {
+		go func() {
+			wg.Wait()
+			synced()
+		}()
+	}
+}
+
+func (r *Replicator) follow(ctx context.Context, name string, st *followState) {
+	for ctx.Err() == nil {
+		err := r.followOnce(ctx, name, st)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, errGap) {
+			r.stats().Add(ctx, "replication_gaps", 1, "name", name)
+			r.logger().Warn(ctx, "cache replication gap detected, resyncing", "name", name, "seq", st.seq)
+
+			continue
+		}
+
+		r.logger().Warn(ctx, "cache replication stream failed", "name", name, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.Config.RetryDelay):
+		}
+	}
+}
+
+func (r *Replicator) followOnce(ctx context.Context, name string, st *followState) error {
+	u, err := url.Parse(r.Config.PrimaryURL)
+	if err != nil {
+		return err
+	}
+
+	q := u.Query()
+	q.Set("name", name)
+	q.Set("epoch", st.epoch)
+	q.Set("since", strconv.FormatUint(st.seq, 10))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	// Compression would buffer the stream.
+	req.Header.Set("Accept-Encoding", "identity")
+
+	if r.Guard != nil {
+		r.Guard.AuthorizeStream(req, name)
+	}
+
+	tr := r.Transport
+	if tr == nil {
+		tr = http.DefaultTransport
+	}
+
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close() //nolint:errcheck // Nothing to do with failed close.
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+
+	if r.Guard != nil {
+		if body, err = r.Guard.DecryptStream(resp.Body); err != nil {
+			return err
+		}
+	}
+
+	return r.caches[name].apply(ctx, gob.NewDecoder(body), st)
+}
+
+func (r *Replicator) heartbeat() time.Duration {
+	if r.Config.Heartbeat <= 0 {
+		return time.Second
+	}
+
+	return r.Config.Heartbeat
+}
+
+func (r *Replicator) stats() stats.Tracker {
+	if r.Stats == nil {
+		return stats.NoOp{}
+	}
+
+	return r.Stats
+}
+
+func (r *Replicator) logger() ctxd.Logger {
+	if r.Logger == nil {
+		return ctxd.NoOpLogger{}
+	}
+
+	return r.Logger
+}
+
+// followState keeps replication position of a follower cache.
+type followState struct {
+	r      *Replicator
+	ctx    context.Context //nolint:containedctx // Context is used for metrics and logs of background job.
+	name   string
+	synced func()
+
+	epoch string
+	seq   uint64
+
+	syncOnce sync.Once
+}
+
+func (st *followState) onResync(snapshot bool) {
+	kind := "replay"
+	if snapshot {
+		kind = "snapshot"
+	}
+
+	st.r.stats().Add(st.ctx, "replication_resyncs", 1, "name", st.name, "kind", kind)
+}
+
+func (st *followState) onSynced() {
+	st.r.logger().Important(st.ctx, "cache replication snapshot received", "name", st.name, "seq", st.seq)
+
+	if st.synced != nil {
+		st.syncOnce.Do(st.synced)
+	}
+}
+
+func (st *followState) onEvent(op string, at time.Time) {
+	st.r.stats().Add(st.ctx, "replication_events", 1, "name", st.name, "op", op)
+	st.r.stats().Set(st.ctx, "replication_lag_seconds", time.Since(at).Seconds(), "name", st.name)
+}
+
+func (st *followState) onHeartbeat(primarySeq uint64) {
+	var lag uint64
+	if primarySeq > st.seq {
+		lag = primarySeq - st.seq
+	}
+
+	st.r.stats().Set(st.ctx, "replication_lag_events", float64(lag), "name", st.name)
+
+	if lag == 0 {
+		st.r.stats().Set(st.ctx, "replication_lag_seconds", 0, "name", st.name)
+	}
+}
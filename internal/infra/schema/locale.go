@@ -0,0 +1,24 @@
+package schema
+
+import (
+	"github.com/swaggest/jsonschema-go"
+	"github.com/swaggest/rest/openapi"
+)
+
+// SetupLocaleEnum sets enum of locale codes to fields tagged with `catalog:"locale"`,
+// request validation and documentation follow locale catalog.
+func SetupLocaleEnum(c *openapi.Collector, codes []string) {
+	enum := make([]interface{}, 0, len(codes))
+	for _, code := range codes {
+		enum = append(enum, code)
+	}
+
+	r := c.Reflector()
+	r.DefaultOptions = append(r.DefaultOptions, jsonschema.InterceptProp(func(params jsonschema.InterceptPropParams) error {
+		if params.Processed && params.Field.Tag.Get("catalog") == "locale" {
+			params.PropertySchema.Enum = enum
+		}
+
+		return nil
+	}))
+}
@@ -1,9 +1,16 @@
 package service
 
 import (
+	"time"
+
 	"github.com/bool64/brick"
 	"github.com/bool64/brick/database"
 	"github.com/bool64/brick/jaeger"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+	"github.com/vearutop/cache-story/internal/infra/chaos"
+	"github.com/vearutop/cache-story/internal/infra/replication"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+	"github.com/vearutop/cache-story/internal/infra/transfer"
 )
 
 // Name is the name of this application or service.
@@ -15,6 +22,50 @@ type Config struct {
 
 	Cache string `split_words:"true" default:"advanced" enum:"none,naive,advanced"`
 
+	// CacheEviction defines eviction strategy, lru and lfu track usage on every read to prioritize cache transfer.
+	CacheEviction string `split_words:"true" default:"most-expired" enum:"most-expired,lru,lfu"`
+
+	CacheTransfer transfer.Config `split_words:"true"`
+
+	// CacheReplication streams cache changes to a hot-standby follower.
+	CacheReplication replication.Config `split_words:"true"`
+
+	// Warmup fills cache with recent greetings if cache was not transferred.
+	Warmup cached.WarmupConfig `split_words:"true"`
+
+	// CacheRestoreTimeout limits time of not-ready state while cache is transferred or warmed up.
+	CacheRestoreTimeout time.Duration `split_words:"true" default:"1m"`
+
+	// RequestTimeout sets deadline to requests, builds are skipped if deadline is shorter than a typical build.
+	RequestTimeout time.Duration `split_words:"true"`
+
+	// BuildCost adds synthetic cost to greeting builds for experiments.
+	BuildCost storage.CostConfig `split_words:"true"`
+
+	// BatchConcurrency limits parallel greeting builds of a batch request.
+	BatchConcurrency int `split_words:"true" default:"8"`
+
+	// GreetingLoader collects greetings of a request into batches on routes with loader.
+	GreetingLoader cached.LoaderConfig `split_words:"true"`
+
+	// InsertBatch enables write-behind batching of greeting inserts.
+	InsertBatch storage.BatchConfig `split_words:"true"`
+
+	// BuildLimit limits parallel greeting builds to protect the database.
+	BuildLimit cached.LimitConfig `split_words:"true"`
+
+	// CircuitBreaker stops greeting builds while the database is failing.
+	CircuitBreaker cached.BreakerConfig `split_words:"true"`
+
+	// BuildLease deduplicates greeting builds across instances with leases in database.
+	BuildLease storage.LeaseConfig `split_words:"true"`
+
+	// GreetingRetention deletes old greetings in background.
+	GreetingRetention storage.RetentionConfig `split_words:"true"`
+
+	// Chaos enables fault injection into greeting maker and database for resilience experiments.
+	Chaos chaos.Config
+
 	Database database.Config `split_words:"true"`
 	Jaeger   jaeger.Config   `split_words:"true"`
 }
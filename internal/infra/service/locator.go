@@ -1,7 +1,14 @@
 package service
 
 import (
+	"net/http"
+
 	"github.com/bool64/brick"
+	"github.com/vearutop/cache-story/internal/infra/cached"
+	"github.com/vearutop/cache-story/internal/infra/chaos"
+	"github.com/vearutop/cache-story/internal/infra/health"
+	"github.com/vearutop/cache-story/internal/infra/replication"
+	"github.com/vearutop/cache-story/internal/infra/transfer"
 )
 
 // Locator defines application resources.
@@ -9,5 +16,29 @@ type Locator struct {
 	*brick.BaseLocator
 
 	GreetingMakerProvider
+	GreetingBatchMakerProvider
 	GreetingClearerProvider
+	GreetingListerProvider
+	GreetingFinderProvider
+	GreetingDeleterProvider
+	GreetingInvalidatorProvider
+	TemplateStorageProvider
+	TemplateInvalidatorProvider
+	LocaleCatalogProvider
+
+	Transfer    *transfer.Transfer
+	Replication *replication.Replicator
+	Readiness   *health.Readiness
+
+	// GreetingLoader binds request-scoped loader of greetings with its middleware.
+	GreetingLoader *cached.GreetingLoader
+
+	// CircuitBreaker is set if enabled in config.
+	CircuitBreaker *cached.CircuitBreaker
+
+	// Chaos is set if fault injection is enabled in config.
+	Chaos *chaos.Injector
+
+	// APIMiddlewares are only applied to API routes, so that debug and cache transfer streams are not affected.
+	APIMiddlewares []func(http.Handler) http.Handler
 }
@@ -9,7 +9,47 @@ type GreetingMakerProvider interface {
 	GreetingMaker() greeting.Maker
 }
 
+// GreetingBatchMakerProvider is a service provider.
+type GreetingBatchMakerProvider interface {
+	GreetingBatchMaker() greeting.BatchMaker
+}
+
+// GreetingListerProvider is a service provider.
+type GreetingListerProvider interface {
+	GreetingLister() greeting.Lister
+}
+
+// GreetingFinderProvider is a service provider.
+type GreetingFinderProvider interface {
+	GreetingFinder() greeting.Finder
+}
+
+// GreetingDeleterProvider is a service provider.
+type GreetingDeleterProvider interface {
+	GreetingDeleter() greeting.Deleter
+}
+
+// GreetingInvalidatorProvider is a service provider.
+type GreetingInvalidatorProvider interface {
+	GreetingInvalidator() greeting.Invalidator
+}
+
 // GreetingClearerProvider is a service provider.
 type GreetingClearerProvider interface {
 	GreetingClearer() greeting.Clearer
 }
+
+// TemplateStorageProvider is a service provider.
+type TemplateStorageProvider interface {
+	TemplateStorage() greeting.TemplateStorage
+}
+
+// TemplateInvalidatorProvider is a service provider.
+type TemplateInvalidatorProvider interface {
+	TemplateInvalidator() greeting.TemplateInvalidator
+}
+
+// LocaleCatalogProvider is a service provider.
+type LocaleCatalogProvider interface {
+	LocaleCatalog() *greeting.Catalog
+}
@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+)
+
+// BatchConfig controls write-behind batching of greeting inserts.
+type BatchConfig struct {
+	// Size is a maximum number of rows in a multi-row insert, zero disables batching.
+	Size int
+
+	// Interval limits time that a greeting waits in buffer before flush.
+	Interval time.Duration `default:"100ms"`
+
+	// Retries is a number of additional attempts to insert a failed row before it is dropped,
+	// failed rows are retried with the next batch.
+	Retries int `default:"3"`
+
+	// MaxPending limits number of failed rows that wait for the next batch, excess rows are dropped.
+	MaxPending int `split_words:"true" default:"10000"`
+}
+
+// NewGreetingWriter creates write-behind buffer of greetings and starts background flushes.
+func NewGreetingWriter(st *sqluct.Storage, cfg BatchConfig, logger ctxd.Logger, tracker stats.Tracker) *GreetingWriter {
+	if cfg.Size <= 0 {
+		cfg.Size = 1
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = 100 * time.Millisecond
+	}
+
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10000
+	}
+
+	w := &GreetingWriter{
+		storage: st,
+		cfg:     cfg,
+		logger:  logger,
+		stats:   tracker,
+		batches: make(chan batch, 1),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// GreetingWriter inserts greetings in batches.
+//
+// A batch is flushed when it reaches BatchConfig.Size, after BatchConfig.Interval, or on Close.
+// Batches are inserted one at a time by a background goroutine, if the database can not keep up,
+// Add blocks until previous batch is flushed.
+//
+// Rows of a failed batch are inserted one by one, rows that still fail are kept and inserted
+// with the next batch, up to BatchConfig.Retries times.
+type GreetingWriter struct {
+	storage *sqluct.Storage
+	cfg     BatchConfig
+	logger  ctxd.Logger
+	stats   stats.Tracker
+
+	mu      sync.Mutex
+	rows    []GreetingRow
+	closing bool
+
+	batches chan batch
+	flushes chan chan error
+	done    chan struct{}
+	closed  chan struct{}
+
+	pending []pendingRow // Failed rows, owned by background goroutine.
+}
+
+type pendingRow struct {
+	row      GreetingRow
+	attempts int
+}
+
+type batch struct {
+	rows   []GreetingRow
+	reason string
+}
+
+// Add puts a row in the buffer.
+func (w *GreetingWriter) Add(ctx context.Context, row GreetingRow) error {
+	w.mu.Lock()
+
+	if w.closing {
+		w.mu.Unlock()
+
+		// Late rows are inserted directly.
+		return w.insert(ctx, []GreetingRow{row})
+	}
+
+	w.rows = append(w.rows, row)
+
+	if len(w.rows) < w.cfg.Size {
+		w.mu.Unlock()
+
+		return nil
+	}
+
+	b := batch{rows: w.rows, reason: "size"}
+	w.rows = nil
+	w.mu.Unlock()
+
+	select {
+	case w.batches <- b:
+		return nil
+	case <-w.closed:
+		return w.insert(ctx, b.rows)
+	}
+}
+
+// Flush inserts buffered rows and waits for completion.
+func (w *GreetingWriter) Flush(ctx context.Context) error {
+	ack := make(chan error, 1)
+
+	select {
+	case w.flushes <- ack:
+	case <-w.closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-ack:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes buffered rows and stops background flushes.
+func (w *GreetingWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closing {
+		w.mu.Unlock()
+
+		return nil
+	}
+
+	w.closing = true
+	w.mu.Unlock()
+
+	close(w.done)
+
+	select {
+	case <-w.closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *GreetingWriter) run() {
+	defer close(w.closed)
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case b := <-w.batches:
+			w.flush(b)
+		case <-ticker.C:
+			w.flush(w.take("time"))
+		case ack := <-w.flushes:
+			err := w.drain()
+
+			if ferr := w.flush(w.take("flush")); ferr != nil {
+				err = ferr
+			}
+
+			ack <- err
+		case <-w.done:
+			w.drain()
+
+			if err := w.flush(w.take("shutdown")); err != nil {
+				w.drop(context.Background(), err, len(w.pending))
+			}
+
+			return
+		}
+	}
+}
+
+// drain flushes batches that are already queued and returns last error.
+func (w *GreetingWriter) drain() error {
+	var err error
+
+	for {
+		select {
+		case b := <-w.batches:
+			if ferr := w.flush(b); ferr != nil {
+				err = ferr
+			}
+		default:
+			return err
+		}
+	}
+}
+
+func (w *GreetingWriter) take(reason string) batch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := batch{rows: w.rows, reason: reason}
+	w.rows = nil
+
+	return b
+}
+
+// flush inserts batch together with pending rows of failed batches, failed rows are kept pending.
+func (w *GreetingWriter) flush(b batch) error {
+	if len(b.rows) == 0 && len(w.pending) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	start := time.Now()
+
+	all := w.pending
+	for _, r := range b.rows {
+		all = append(all, pendingRow{row: r})
+	}
+
+	rows := make([]GreetingRow, len(all))
+	for i, p := range all {
+		rows[i] = p.row
+	}
+
+	var failed []pendingRow
+
+	err := w.insert(ctx, rows)
+	if err != nil {
+		failed = all
+
+		// Rows of a failed batch are inserted one by one, so that a bad row does not fail others.
+		if len(all) > 1 {
+			failed = nil
+
+			for _, p := range all {
+				if rerr := w.insert(ctx, []GreetingRow{p.row}); rerr != nil {
+					failed = append(failed, p)
+					err = rerr
+				}
+			}
+		}
+	}
+
+	w.stats.Add(ctx, "greeting_batch_flushes", 1, "reason", b.reason)
+	w.stats.Add(ctx, "greeting_batch_rows", float64(len(rows)), "reason", b.reason)
+	w.stats.Set(ctx, "greeting_batch_size", float64(len(rows)))
+	w.stats.Add(ctx, "greeting_batch_flush_seconds", time.Since(start).Seconds())
+
+	w.pending = nil
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	w.requeue(ctx, err, failed)
+
+	return err
+}
+
+// requeue keeps failed rows for the next batch, rows that ran out of retries or exceed the limit are dropped.
+func (w *GreetingWriter) requeue(ctx context.Context, err error, failed []pendingRow) {
+	pending := make([]pendingRow, 0, len(failed))
+	dropped := 0
+
+	for _, p := range failed {
+		p.attempts++
+
+		if p.attempts > w.cfg.Retries {
+			dropped++
+
+			continue
+		}
+
+		pending = append(pending, p)
+	}
+
+	// Oldest rows are dropped first.
+	if excess := len(pending) - w.cfg.MaxPending; excess > 0 {
+		pending = pending[excess:]
+		dropped += excess
+	}
+
+	w.pending = pending
+
+	if dropped > 0 {
+		w.drop(ctx, err, dropped)
+	} else {
+		w.logger.Warn(ctx, "failed to insert greetings batch, retrying with next batch",
+			"error", err, "pending", len(w.pending))
+	}
+}
+
+func (w *GreetingWriter) drop(ctx context.Context, err error, n int) {
+	w.stats.Add(ctx, "greeting_batch_dropped", float64(n))
+	w.logger.Error(ctx, "failed to insert greetings batch", "error", err, "dropped", n, "pending", len(w.pending))
+}
+
+func (w *GreetingWriter) insert(ctx context.Context, rows []GreetingRow) error {
+	q := w.storage.InsertStmt(GreetingsTable, rows, sqluct.InsertIgnore)
+
+	if _, err := w.storage.Exec(ctx, q); err != nil {
+		return ctxd.WrapError(ctx, err, "failed to store greetings")
+	}
+
+	return nil
+}
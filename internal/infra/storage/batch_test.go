@@ -0,0 +1,202 @@
+package storage_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+)
+
+func countGreetings(t *testing.T, st *sqluct.Storage) int {
+	t.Helper()
+
+	var cnt int
+
+	require.NoError(t, st.DB().QueryRow("SELECT COUNT(*) FROM "+storage.GreetingsTable).Scan(&cnt))
+
+	return cnt
+}
+
+func TestGreetingWriter_Add(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	tr := &stats.TrackerMock{}
+	w := storage.NewGreetingWriter(st, storage.BatchConfig{Size: 7, Interval: 20 * time.Millisecond}, ctxd.NoOpLogger{}, tr)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: tr, Writer: w}
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				name := strconv.Itoa(i) + "-" + strconv.Itoa(j)
+
+				g, err := gs.Hello(ctx, greeting.Params{Name: name, Locale: "en-US"})
+				assert.NoError(t, err)
+				assert.Equal(t, "Hello, "+name+"!", g.Message)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	require.NoError(t, w.Close(ctx))
+
+	// Every greeting has landed in the table.
+	assert.Equal(t, 1000, countGreetings(t, st))
+
+	rows := tr.Int("greeting_batch_rows", "reason", "size") +
+		tr.Int("greeting_batch_rows", "reason", "time") +
+		tr.Int("greeting_batch_rows", "reason", "shutdown")
+	assert.Equal(t, 1000, rows)
+	assert.Greater(t, tr.Int("greeting_batch_flushes", "reason", "size"), 100)
+	assert.LessOrEqual(t, tr.Value("greeting_batch_size"), 7.0)
+	assert.Greater(t, tr.Value("greeting_batch_flush_seconds"), 0.0)
+	assert.Equal(t, 0, tr.Int("greeting_batch_dropped"))
+
+	// Rows that come after close are inserted directly.
+	_, err := gs.Hello(ctx, greeting.Params{Name: "late", Locale: "en-US"})
+	require.NoError(t, err)
+	assert.Equal(t, 1001, countGreetings(t, st))
+}
+
+func TestGreetingWriter_Add_interval(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	tr := &stats.TrackerMock{}
+	w := storage.NewGreetingWriter(st, storage.BatchConfig{Size: 100, Interval: 10 * time.Millisecond}, ctxd.NoOpLogger{}, tr)
+
+	defer func() {
+		require.NoError(t, w.Close(ctx))
+	}()
+
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: tr, Writer: w}
+
+	_, err := gs.Hello(ctx, greeting.Params{Name: "a", Locale: "en-US"})
+	require.NoError(t, err)
+
+	// Incomplete batch is flushed by time.
+	assert.Eventually(t, func() bool {
+		return countGreetings(t, st) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, 1, tr.Int("greeting_batch_flushes", "reason", "time"))
+
+	// Clearing flushes buffered greetings first.
+	_, err = gs.Hello(ctx, greeting.Params{Name: "b", Locale: "en-US"})
+	require.NoError(t, err)
+
+	n, err := gs.ClearGreetings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 0, countGreetings(t, st))
+}
+
+func TestGreetingWriter_Flush_recover(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	tr := &stats.TrackerMock{}
+	w := storage.NewGreetingWriter(st, storage.BatchConfig{Size: 100, Interval: time.Hour, Retries: 1}, ctxd.NoOpLogger{}, tr)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: tr, Writer: w}
+
+	// Database fails.
+	_, err := st.DB().Exec("ALTER TABLE " + storage.GreetingsTable + " RENAME TO greetings_off")
+	require.NoError(t, err)
+
+	_, err = gs.Hello(ctx, greeting.Params{Name: "a", Locale: "en-US"})
+	require.NoError(t, err)
+	require.Error(t, w.Flush(ctx))
+
+	// Database recovers, failed row is inserted with the next batch.
+	_, err = st.DB().Exec("ALTER TABLE greetings_off RENAME TO " + storage.GreetingsTable)
+	require.NoError(t, err)
+
+	_, err = gs.Hello(ctx, greeting.Params{Name: "b", Locale: "en-US"})
+	require.NoError(t, err)
+	require.NoError(t, w.Flush(ctx))
+
+	assert.Equal(t, 2, countGreetings(t, st))
+	assert.Equal(t, 0, tr.Int("greeting_batch_dropped"))
+	require.NoError(t, w.Close(ctx))
+}
+
+func TestGreetingWriter_Flush_dropped(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	tr := &stats.TrackerMock{}
+	w := storage.NewGreetingWriter(st, storage.BatchConfig{Size: 100, Interval: time.Hour, Retries: 1, MaxPending: 2}, ctxd.NoOpLogger{}, tr)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: tr, Writer: w}
+
+	_, err := st.DB().Exec("ALTER TABLE " + storage.GreetingsTable + " RENAME TO greetings_off")
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, err = gs.Hello(ctx, greeting.Params{Name: name, Locale: "en-US"})
+		require.NoError(t, err)
+	}
+
+	// Rows above the limit are dropped.
+	require.Error(t, w.Flush(ctx))
+	assert.Equal(t, 1, tr.Int("greeting_batch_dropped"))
+
+	// Rows that ran out of retries are dropped.
+	require.Error(t, w.Flush(ctx))
+	assert.Equal(t, 3, tr.Int("greeting_batch_dropped"))
+
+	_, err = gs.Hello(ctx, greeting.Params{Name: "d", Locale: "en-US"})
+	require.NoError(t, err)
+
+	// Close does not wait for retries and drops rows that failed on shutdown.
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	require.NoError(t, w.Close(ctx))
+	assert.Equal(t, 4, tr.Int("greeting_batch_dropped"))
+}
+
+func TestGreetingWriter_Flush_badRow(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	tr := &stats.TrackerMock{}
+	w := storage.NewGreetingWriter(st, storage.BatchConfig{Size: 100, Interval: time.Hour, Retries: 1}, ctxd.NoOpLogger{}, tr)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: tr, Writer: w}
+
+	// Database rejects a single row.
+	_, err := st.DB().Exec("CREATE TRIGGER bad_row BEFORE INSERT ON " + storage.GreetingsTable +
+		" WHEN NEW.name = 'bad' BEGIN SELECT RAISE(ABORT, 'bad row'); END")
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "bad", "b"} {
+		_, err = gs.Hello(ctx, greeting.Params{Name: name, Locale: "en-US"})
+		require.NoError(t, err)
+	}
+
+	// Good rows are stored, bad row is retried.
+	require.Error(t, w.Flush(ctx))
+	assert.Equal(t, 2, countGreetings(t, st))
+	assert.Equal(t, 0, tr.Int("greeting_batch_dropped"))
+
+	// Bad row does not fail next batch and is dropped after retries.
+	_, err = gs.Hello(ctx, greeting.Params{Name: "c", Locale: "en-US"})
+	require.NoError(t, err)
+	require.Error(t, w.Flush(ctx))
+	assert.Equal(t, 3, countGreetings(t, st))
+	assert.Equal(t, 1, tr.Int("greeting_batch_dropped"))
+
+	require.NoError(t, w.Flush(ctx))
+	require.NoError(t, w.Close(ctx))
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+)
+
+// Cost models.
+const (
+	CostNone      = "none"
+	CostFixed     = "fixed"
+	CostLogNormal = "lognormal"
+	CostCPU       = "cpu"
+	CostQuery     = "query"
+)
+
+// CostConfig controls synthetic cost of building a greeting.
+type CostConfig struct {
+	// Model is one of none, fixed (delay), lognormal (delay), cpu (burn loop), query (extra database query).
+	Model string `default:"query" enum:"none,fixed,lognormal,cpu,query"`
+
+	// Weight scales the cost of a model: delay duration, number of CPU iterations or number of rows
+	// aggregated by the query.
+	Weight float64 `default:"1"`
+
+	// Delay is a fixed delay or a median of log-normal delay with weight 1.
+	Delay time.Duration `default:"1ms"`
+
+	// Sigma is a shape of log-normal delay, larger values make a longer tail.
+	Sigma float64 `default:"0.5"`
+
+	// Iterations is a number of hashing rounds of CPU burn with weight 1.
+	Iterations int `default:"10000"`
+
+	// Rows is a number of rows aggregated by extra query with weight 1.
+	Rows int `default:"1000"`
+}
+
+// CostModel adds synthetic cost to a greeting build.
+type CostModel interface {
+	Spend(ctx context.Context) error
+}
+
+// NewCostModel creates cost model of configured type.
+//
+// Time spent by the model is tracked as build_cost_seconds metric, so that benchmarks on
+// different machines and drivers can be compared without synthetic load.
+func NewCostModel(cfg CostConfig, st *sqluct.Storage, tracker stats.Tracker) (CostModel, error) {
+	if cfg.Weight < 0 {
+		return nil, fmt.Errorf("negative cost weight: %f", cfg.Weight)
+	}
+
+	var spend func(ctx context.Context) error
+
+	switch cfg.Model {
+	case CostNone, "":
+		return nil, nil
+	case CostFixed:
+		d := time.Duration(cfg.Weight * float64(cfg.Delay))
+		spend = func(ctx context.Context) error {
+			return sleep(ctx, d)
+		}
+	case CostLogNormal:
+		spend = func(ctx context.Context) error {
+			f := cfg.Weight * float64(cfg.Delay) * math.Exp(rand.NormFloat64()*cfg.Sigma) //nolint:gosec // Weak randomness is fine here.
+
+			return sleep(ctx, time.Duration(f))
+		}
+	case CostCPU:
+		n := int(cfg.Weight * float64(cfg.Iterations))
+		spend = func(ctx context.Context) error {
+			burn(n)
+
+			return nil
+		}
+	case CostQuery:
+		q := avgIDQuery{storage: st, stats: tracker, limit: int(cfg.Weight * float64(cfg.Rows))}
+		spend = q.run
+	default:
+		return nil, fmt.Errorf("unknown cost model: %s", cfg.Model)
+	}
+
+	return costFunc(func(ctx context.Context) error {
+		start := time.Now()
+		err := spend(ctx)
+
+		tracker.Add(ctx, "build_cost_seconds", time.Since(start).Seconds(), "model", cfg.Model)
+
+		return err
+	}), nil
+}
+
+type costFunc func(ctx context.Context) error
+
+func (f costFunc) Spend(ctx context.Context) error {
+	return f(ctx)
+}
+
+// avgIDQuery makes things sloooooower 🐌.
+type avgIDQuery struct {
+	storage *sqluct.Storage
+	stats   stats.Tracker
+	limit   int
+}
+
+func (q avgIDQuery) run(ctx context.Context) error {
+	var avg sql.NullFloat64
+
+	qb := q.storage.QueryBuilder().Select("AVG(id)").From(GreetingsTable).Where(squirrel.Lt{"id": q.limit})
+
+	if err := q.storage.Select(ctx, qb, &avg); err != nil {
+		return ctxd.WrapError(ctx, err, "failed to calculate average id")
+	}
+
+	q.stats.Set(ctx, "avg_id", avg.Float64)
+
+	return nil
+}
+
+// burnSink keeps CPU burn result alive to prevent optimization.
+var burnSink uint64
+
+func burn(n int) {
+	var h [sha256.Size]byte
+
+	for i := 0; i < n; i++ {
+		h = sha256.Sum256(h[:])
+	}
+
+	atomic.StoreUint64(&burnSink, binary.LittleEndian.Uint64(h[:]))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+)
+
+func TestNewCostModel(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	for _, model := range []string{storage.CostFixed, storage.CostLogNormal, storage.CostCPU, storage.CostQuery} {
+		t.Run(model, func(t *testing.T) {
+			tr := &stats.TrackerMock{}
+
+			cm, err := storage.NewCostModel(storage.CostConfig{
+				Model:      model,
+				Weight:     2,
+				Delay:      5 * time.Millisecond,
+				Sigma:      0.1,
+				Iterations: 1000,
+				Rows:       10,
+			}, st, tr)
+			require.NoError(t, err)
+
+			gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: tr, Cost: cm}
+
+			start := time.Now()
+			g, err := gs.Hello(ctx, greeting.Params{Name: model, Locale: "en-US"})
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, "+model+"!", g.Message)
+
+			spent := tr.Value("build_cost_seconds", "model", model)
+			assert.Greater(t, spent, 0.0)
+
+			if model == storage.CostFixed {
+				assert.GreaterOrEqual(t, spent, 0.01)
+				assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+			}
+		})
+	}
+
+	cm, err := storage.NewCostModel(storage.CostConfig{Model: storage.CostNone}, st, stats.NoOp{})
+	require.NoError(t, err)
+	assert.Nil(t, cm)
+
+	_, err = storage.NewCostModel(storage.CostConfig{Model: "magic"}, st, stats.NoOp{})
+	require.EqualError(t, err, "unknown cost model: magic")
+}
@@ -2,8 +2,11 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"time"
 
+	"github.com/Masterminds/squirrel"
 	"github.com/bool64/ctxd"
 	"github.com/bool64/sqluct"
 	"github.com/bool64/stats"
@@ -15,6 +18,15 @@ type GreetingSaver struct {
 	Upstream greeting.Maker
 	Storage  *sqluct.Storage
 	Stats    stats.Tracker
+
+	// Cost adds synthetic cost to every build, optional.
+	Cost CostModel
+
+	// Writer inserts greetings in batches in background, optional.
+	Writer *GreetingWriter
+
+	// BatchConcurrency limits parallel builds of HelloBatch, builds are sequential if it is not positive.
+	BatchConcurrency int
 }
 
 // GreetingsTable is the name of the table.
@@ -24,49 +36,273 @@ const GreetingsTable = "greetings"
 type GreetingRow struct {
 	ID        int       `db:"id,omitempty"`
 	Message   string    `db:"message"`
+	Name      string    `db:"name"`
+	Locale    string    `db:"locale"`
 	CreatedAt time.Time `db:"created_at"`
+
+	TemplateVersion int `db:"template_version"`
 }
 
 // Hello makes a greeting with Upstream and stores it in database before returning.
-func (gs *GreetingSaver) Hello(ctx context.Context, params greeting.Params) (string, error) {
+//
+// Greeting gets ID of stored row, ID is not available if greeting is inserted by Writer.
+func (gs *GreetingSaver) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
 	g, err := gs.Upstream.Hello(ctx, params)
 	if err != nil {
 		return g, err
 	}
 
-	q := gs.Storage.InsertStmt(GreetingsTable, GreetingRow{
-		Message:   g,
-		CreatedAt: time.Now(),
-	}, sqluct.InsertIgnore)
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now()
+	}
+
+	row := newGreetingRow(params, g)
 
-	if _, err = gs.Storage.Exec(ctx, q); err != nil {
-		return "", ctxd.WrapError(ctx, err, "failed to store greeting")
+	if gs.Writer != nil {
+		if err = gs.Writer.Add(ctx, row); err != nil {
+			return greeting.Greeting{}, err
+		}
+	} else if g, err = gs.insert(ctx, row, g); err != nil {
+		return greeting.Greeting{}, err
 	}
 
-	if err = gs.calcAvgID(ctx); err != nil {
-		return "", err
+	if gs.Cost != nil {
+		if err = gs.Cost.Spend(ctx); err != nil {
+			return greeting.Greeting{}, err
+		}
 	}
 
 	return g, nil
 }
 
-// calcAvgID makes things sloooooower 🐌.
-func (gs *GreetingSaver) calcAvgID(ctx context.Context) error {
-	r := gs.Storage.DB().QueryRowContext(ctx, "SELECT AVG(id) FROM "+GreetingsTable+" WHERE id < 1000")
+// insert stores greeting and sets its ID, previously stored greeting is returned if it exists.
+//
+// Insert is retried once if previously stored greeting is deleted before it is found.
+func (gs *GreetingSaver) insert(ctx context.Context, row GreetingRow, g greeting.Greeting) (greeting.Greeting, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := gs.Storage.Exec(ctx, gs.Storage.InsertStmt(GreetingsTable, row, sqluct.InsertIgnore))
+		if err != nil {
+			return g, ctxd.WrapError(ctx, err, "failed to store greeting")
+		}
 
-	var avg int
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return insertedGreeting(ctx, res, g)
+		}
 
-	if err := r.Scan(&avg); err != nil {
-		return r.Err()
+		stored, err := greetingByParams(ctx, gs.Storage, greeting.Params{Name: row.Name, Locale: row.Locale})
+		if errors.Is(err, greeting.ErrNotFound) && attempt == 0 {
+			continue
+		}
+
+		if err != nil {
+			return g, err
+		}
+
+		return stored.greeting(), nil
 	}
+}
 
-	gs.Stats.Set(ctx, "avg_id", float64(avg))
+// insertedGreeting sets ID of inserted greeting.
+func insertedGreeting(ctx context.Context, res sql.Result, g greeting.Greeting) (greeting.Greeting, error) {
+	id, err := res.LastInsertId()
+	if err != nil {
+		return g, ctxd.WrapError(ctx, err, "failed to get greeting ID")
+	}
 
-	return nil
+	g.ID = int(id)
+
+	return g, nil
+}
+
+// HelloBatch makes greetings with Upstream in parallel and stores them in database with a single insert.
+//
+// Items that failed to build or store are reported with errors, other items are stored.
+func (gs *GreetingSaver) HelloBatch(ctx context.Context, params []greeting.Params) []greeting.Result {
+	res := greeting.HelloEach(ctx, params, gs.BatchConcurrency, func(ctx context.Context, p greeting.Params) (greeting.Greeting, error) {
+		g, err := gs.Upstream.Hello(ctx, p)
+		if err != nil {
+			return g, err
+		}
+
+		if g.CreatedAt.IsZero() {
+			g.CreatedAt = time.Now()
+		}
+
+		if gs.Cost != nil {
+			if err = gs.Cost.Spend(ctx); err != nil {
+				return greeting.Greeting{}, err
+			}
+		}
+
+		return g, nil
+	})
+
+	var (
+		rows  []GreetingRow
+		items []int
+	)
+
+	for i, r := range res {
+		if r.Err == nil {
+			rows = append(rows, newGreetingRow(params[i], r.Greeting))
+			items = append(items, i)
+		}
+	}
+
+	if len(rows) == 0 {
+		return res
+	}
+
+	if gs.Writer != nil {
+		for j, row := range rows {
+			if err := gs.Writer.Add(ctx, row); err != nil {
+				res[items[j]] = greeting.Result{Err: err}
+			}
+		}
+
+		return res
+	}
+
+	stored, err := gs.insertBatch(ctx, rows)
+
+	for j, i := range items {
+		if err != nil {
+			res[i] = greeting.Result{Err: err}
+
+			continue
+		}
+
+		// Greeting stays without ID if its row was removed right after insert.
+		row, found := stored[params[i]]
+		if !found {
+			continue
+		}
+
+		// Previously stored greeting with other message takes precedence, as in Hello.
+		if row.Message != rows[j].Message {
+			res[i].Greeting = row.greeting()
+		} else {
+			res[i].Greeting.ID = row.ID
+		}
+	}
+
+	return res
+}
+
+// insertBatch stores greetings with a single insert and returns stored rows by params.
+func (gs *GreetingSaver) insertBatch(ctx context.Context, rows []GreetingRow) (map[greeting.Params]GreetingRow, error) {
+	if _, err := gs.Storage.Exec(ctx, gs.Storage.InsertStmt(GreetingsTable, rows, sqluct.InsertIgnore)); err != nil {
+		return nil, ctxd.WrapError(ctx, err, "failed to store greetings")
+	}
+
+	var (
+		where  squirrel.Or
+		stored []GreetingRow
+	)
+
+	for _, r := range rows {
+		where = append(where, gs.Storage.WhereEq(GreetingRow{Name: r.Name, Locale: r.Locale}, sqluct.Columns("name", "locale")))
+	}
+
+	if err := gs.Storage.Select(ctx, gs.Storage.SelectStmt(GreetingsTable, GreetingRow{}).Where(where), &stored); err != nil {
+		return nil, ctxd.WrapError(ctx, err, "failed to find stored greetings")
+	}
+
+	res := make(map[greeting.Params]GreetingRow, len(stored))
+	for _, r := range stored {
+		res[greeting.Params{Name: r.Name, Locale: r.Locale}] = r
+	}
+
+	return res, nil
+}
+
+// GreetingByParams returns stored greeting, greeting.ErrNotFound is returned if it does not exist.
+func (gs *GreetingSaver) GreetingByParams(ctx context.Context, params greeting.Params) (GreetingRow, error) {
+	return greetingByParams(ctx, gs.Storage, params)
+}
+
+func greetingByParams(ctx context.Context, st *sqluct.Storage, params greeting.Params) (GreetingRow, error) {
+	var row GreetingRow
+
+	q := st.SelectStmt(GreetingsTable, row).
+		Where(st.WhereEq(GreetingRow{Name: params.Name, Locale: params.Locale}, sqluct.Columns("name", "locale")))
+
+	if err := st.Select(ctx, q, &row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return row, greeting.ErrNotFound
+		}
+
+		return row, ctxd.WrapError(ctx, err, "failed to find greeting")
+	}
+
+	return row, nil
+}
+
+func newGreetingRow(params greeting.Params, g greeting.Greeting) GreetingRow {
+	return GreetingRow{
+		Message:         g.Message,
+		Name:            params.Name,
+		Locale:          params.Locale,
+		CreatedAt:       g.CreatedAt.UTC(),
+		TemplateVersion: g.TemplateVersion,
+	}
+}
+
+// sqliteTime is a format of current_timestamp in SQLite with fractional seconds.
+const sqliteTime = "2006-01-02 15:04:05.999999999"
+
+// createdAtArg returns an argument to compare with created_at.
+//
+// SQLite stores times as text and compares them as strings, so the argument is formatted in UTC
+// like current_timestamp, greetings are written in UTC to match.
+func createdAtArg(st *sqluct.Storage, t time.Time) interface{} {
+	if st.Mapper != nil && st.Mapper.Dialect == sqluct.DialectSQLite3 {
+		return t.UTC().Format(sqliteTime)
+	}
+
+	return t
+}
+
+func (r GreetingRow) greeting() greeting.Greeting {
+	return greeting.Greeting{
+		ID:              r.ID,
+		Message:         r.Message,
+		Locale:          r.Locale,
+		TemplateVersion: r.TemplateVersion,
+		CreatedAt:       r.CreatedAt,
+	}
+}
+
+// FindRecentParams returns params of the most recent greetings.
+func (gs *GreetingSaver) FindRecentParams(ctx context.Context, limit int) ([]greeting.Params, error) {
+	var rows []GreetingRow
+
+	q := gs.Storage.SelectStmt(GreetingsTable, GreetingRow{}, sqluct.Columns("name", "locale")).
+		Where("locale != ''").
+		OrderBy("id DESC").
+		Limit(uint64(limit))
+
+	if err := gs.Storage.Select(ctx, q, &rows); err != nil {
+		return nil, ctxd.WrapError(ctx, err, "failed to find recent greetings")
+	}
+
+	params := make([]greeting.Params, 0, len(rows))
+	for _, r := range rows {
+		params = append(params, greeting.Params{Name: r.Name, Locale: r.Locale})
+	}
+
+	return params, nil
 }
 
 // ClearGreetings removes all entries.
 func (gs *GreetingSaver) ClearGreetings(ctx context.Context) (int, error) {
+	// Buffered greetings are flushed first, so that they do not appear after removal.
+	if gs.Writer != nil {
+		if err := gs.Writer.Flush(ctx); err != nil {
+			return 0, err
+		}
+	}
+
 	res, err := gs.Storage.DeleteStmt(GreetingsTable).ExecContext(ctx)
 	if err != nil {
 		return 0, err
@@ -86,6 +322,15 @@ func (gs *GreetingSaver) GreetingMaker() greeting.Maker {
 	return gs
 }
 
+// GreetingBatchMaker implements service provider.
+func (gs *GreetingSaver) GreetingBatchMaker() greeting.BatchMaker {
+	if gs == nil {
+		panic("empty GreetingSaver")
+	}
+
+	return gs
+}
+
 // GreetingClearer implements service provider.
 func (gs *GreetingSaver) GreetingClearer() greeting.Clearer {
 	if gs == nil {
@@ -0,0 +1,203 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bool64/brick/database"
+	"github.com/bool64/ctxd"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+	"github.com/vearutop/cache-story/internal/infra/storage/sqlite"
+)
+
+func TestGreetingSaver_GreetingByParams(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: stats.NoOp{}}
+
+	_, err := gs.GreetingByParams(ctx, greeting.Params{Name: "a", Locale: "ru-RU"})
+	require.ErrorIs(t, err, greeting.ErrNotFound)
+
+	_, err = gs.Hello(ctx, greeting.Params{Name: "a", Locale: "ru-RU"})
+	require.NoError(t, err)
+
+	row, err := gs.GreetingByParams(ctx, greeting.Params{Name: "a", Locale: "ru-RU"})
+	require.NoError(t, err)
+	assert.Equal(t, "Привет, a!", row.Message)
+	assert.NotZero(t, row.ID)
+	assert.NotZero(t, row.CreatedAt)
+}
+
+func TestGreetingSaver_Hello_sameMessage(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	gs := &storage.GreetingSaver{Upstream: hiMaker{}, Storage: st, Stats: stats.NoOp{}}
+
+	// Locales with the same message are stored separately.
+	pt, err := gs.Hello(ctx, greeting.Params{Name: "a", Locale: "pt"})
+	require.NoError(t, err)
+
+	br, err := gs.Hello(ctx, greeting.Params{Name: "a", Locale: "pt-BR"})
+	require.NoError(t, err)
+
+	assert.Equal(t, pt.Message, br.Message)
+	assert.NotZero(t, pt.ID)
+	assert.NotZero(t, br.ID)
+	assert.NotEqual(t, pt.ID, br.ID)
+
+	// Previously stored greeting is returned for the same params.
+	again, err := gs.Hello(ctx, greeting.Params{Name: "a", Locale: "pt-BR"})
+	require.NoError(t, err)
+	assert.Equal(t, br.ID, again.ID)
+}
+
+type hiMaker struct{}
+
+func (hiMaker) Hello(_ context.Context, params greeting.Params) (greeting.Greeting, error) {
+	if params.Name == "Bug" {
+		return greeting.Greeting{}, errors.New("failed")
+	}
+
+	return greeting.Greeting{Message: "Hi, " + params.Name + "!", Locale: params.Locale, TemplateVersion: 2}, nil
+}
+
+func TestGreetingSaver_HelloBatch(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: stats.NoOp{}, BatchConcurrency: 2}
+
+	stored, err := gs.Hello(ctx, greeting.Params{Name: "a", Locale: "en-US"})
+	require.NoError(t, err)
+
+	gs.Upstream = hiMaker{}
+
+	res := gs.HelloBatch(ctx, []greeting.Params{
+		{Name: "a", Locale: "en-US"},
+		{Name: "b", Locale: "en-US"},
+		{Name: "Bug", Locale: "en-US"},
+		{Name: "c", Locale: "ru-RU"},
+	})
+	require.Len(t, res, 4)
+
+	// Previously stored greeting is served.
+	assert.NoError(t, res[0].Err)
+	assert.Equal(t, stored.ID, res[0].Greeting.ID)
+	assert.Equal(t, "Hello, a!", res[0].Greeting.Message)
+
+	assert.NoError(t, res[1].Err)
+	assert.NotZero(t, res[1].Greeting.ID)
+	assert.Equal(t, "Hi, b!", res[1].Greeting.Message)
+	assert.Equal(t, 2, res[1].Greeting.TemplateVersion)
+	assert.NotZero(t, res[1].Greeting.CreatedAt)
+
+	assert.Error(t, res[2].Err)
+
+	assert.Equal(t, "Hi, c!", res[3].Greeting.Message)
+	assert.NotEqual(t, res[1].Greeting.ID, res[3].Greeting.ID)
+	assert.Equal(t, 3, countGreetings(t, st))
+
+	row, err := gs.GreetingByParams(ctx, greeting.Params{Name: "c", Locale: "ru-RU"})
+	require.NoError(t, err)
+	assert.Equal(t, res[3].Greeting.ID, row.ID)
+	assert.Equal(t, 2, row.TemplateVersion)
+}
+
+func TestGreetingSaver_FindRecentParams(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: stats.NoOp{}}
+
+	params, err := gs.FindRecentParams(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, params)
+
+	for _, p := range []greeting.Params{{Name: "a", Locale: "en-US"}, {Name: "b", Locale: "ru-RU"}, {Name: "c", Locale: "en-US"}} {
+		_, err := gs.Hello(ctx, p)
+		require.NoError(t, err)
+	}
+
+	// Rows without locale can not be rebuilt and are skipped.
+	_, err = st.Exec(ctx, st.InsertStmt(storage.GreetingsTable, storage.GreetingRow{Message: "Hi, d!", Name: "d"}))
+	require.NoError(t, err)
+
+	// Most recent greetings come first.
+	params, err = gs.FindRecentParams(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []greeting.Params{{Name: "c", Locale: "en-US"}, {Name: "b", Locale: "ru-RU"}, {Name: "a", Locale: "en-US"}}, params)
+
+	params, err = gs.FindRecentParams(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []greeting.Params{{Name: "c", Locale: "en-US"}, {Name: "b", Locale: "ru-RU"}}, params)
+}
+
+func TestMigrations_backfillParams(t *testing.T) {
+	ctx := context.Background()
+	cfg := database.Config{
+		DriverName:      "sqlite",
+		DSN:             filepath.Join(t.TempDir(), "db.sqlite"),
+		MaxOpen:         1,
+		ApplyMigrations: true,
+	}
+
+	// Applying migrations that precede params index.
+	legacy := fstest.MapFS{}
+
+	require.NoError(t, fs.WalkDir(sqlite.Migrations, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path >= "20261019120000_greetings_params_index.sql" {
+			return err
+		}
+
+		data, err := fs.ReadFile(sqlite.Migrations, path)
+		legacy[path] = &fstest.MapFile{Data: data}
+
+		return err
+	}))
+
+	st, err := database.SetupStorageDSN(cfg, ctxd.NoOpLogger{}, stats.NoOp{}, legacy)
+	require.NoError(t, err)
+
+	for _, msg := range []string{"Hello, Ann!", "Привет, Боб!", "#$@@^!"} {
+		_, err = st.DB().Exec("INSERT INTO greetings (message) VALUES (?)", msg)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, st.DB().Close())
+
+	st, err = database.SetupStorageDSN(cfg, ctxd.NoOpLogger{}, stats.NoOp{}, sqlite.Migrations)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, st.DB().Close())
+	}()
+
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: stats.NoOp{}}
+
+	row, err := gs.GreetingByParams(ctx, greeting.Params{Name: "Ann", Locale: "en-US"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Ann!", row.Message)
+
+	row, err = gs.GreetingByParams(ctx, greeting.Params{Name: "Боб", Locale: "ru-RU"})
+	require.NoError(t, err)
+	assert.Equal(t, "Привет, Боб!", row.Message)
+
+	var cnt int
+
+	require.NoError(t, st.DB().QueryRow("SELECT COUNT(*) FROM greetings").Scan(&cnt))
+	assert.Equal(t, 2, cnt)
+
+	// Params are unique.
+	_, err = st.DB().Exec("INSERT INTO greetings (message, name, locale) VALUES ('Hello again, Ann!', 'Ann', 'en-US')")
+	require.Error(t, err)
+
+	// Message is not unique.
+	_, err = st.DB().Exec("INSERT INTO greetings (message, name, locale) VALUES ('Hello, Ann!', 'Ann', 'en-GB')")
+	require.NoError(t, err)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bool64/ctxd"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// ListGreetings returns a page of stored greetings, newest first.
+func (gs *GreetingSaver) ListGreetings(ctx context.Context, filter greeting.ListFilter) (greeting.List, error) {
+	res := greeting.List{Items: []greeting.Stored{}}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+
+	var row GreetingRow
+
+	where := squirrel.And{}
+
+	if filter.Locale != "" {
+		where = append(where, squirrel.Eq{gs.Storage.Col(&row, &row.Locale): filter.Locale})
+	}
+
+	if filter.NamePrefix != "" {
+		where = append(where, squirrel.Expr(gs.Storage.Col(&row, &row.Name)+" LIKE ? ESCAPE '!'", likePrefix(filter.NamePrefix)))
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		where = append(where, squirrel.GtOrEq{gs.Storage.Col(&row, &row.CreatedAt): createdAtArg(gs.Storage, filter.CreatedAfter)})
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, squirrel.Lt{gs.Storage.Col(&row, &row.CreatedAt): createdAtArg(gs.Storage, filter.CreatedBefore)})
+	}
+
+	cq := gs.Storage.QueryBuilder().Select("COUNT(*)").From(GreetingsTable).Where(where)
+	if err := gs.Storage.Select(ctx, cq, &res.Total); err != nil {
+		return res, ctxd.WrapError(ctx, err, "failed to count greetings")
+	}
+
+	if filter.Cursor != "" {
+		id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return res, err
+		}
+
+		where = append(where, squirrel.Lt{gs.Storage.Col(&row, &row.ID): id})
+	}
+
+	var rows []GreetingRow
+
+	// One extra row shows if there is a next page.
+	q := gs.Storage.SelectStmt(GreetingsTable, row).
+		Where(where).
+		OrderBy(gs.Storage.Col(&row, &row.ID) + " DESC").
+		Limit(uint64(filter.Limit + 1))
+
+	if err := gs.Storage.Select(ctx, q, &rows); err != nil {
+		return res, ctxd.WrapError(ctx, err, "failed to list greetings")
+	}
+
+	if len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+		res.NextCursor = encodeCursor(rows[len(rows)-1].ID)
+	}
+
+	for _, r := range rows {
+		res.Items = append(res.Items, r.stored())
+	}
+
+	return res, nil
+}
+
+// GreetingByID returns stored greeting.
+func (gs *GreetingSaver) GreetingByID(ctx context.Context, id int) (greeting.Stored, error) {
+	var row GreetingRow
+
+	q := gs.Storage.SelectStmt(GreetingsTable, row).
+		Where(squirrel.Eq{gs.Storage.Col(&row, &row.ID): id})
+
+	if err := gs.Storage.Select(ctx, q, &row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return greeting.Stored{}, greeting.ErrNotFound
+		}
+
+		return greeting.Stored{}, ctxd.WrapError(ctx, err, "failed to find greeting")
+	}
+
+	return row.stored(), nil
+}
+
+// DeleteGreeting removes stored greeting and returns it.
+func (gs *GreetingSaver) DeleteGreeting(ctx context.Context, id int) (greeting.Stored, error) {
+	var res greeting.Stored
+
+	err := gs.Storage.InTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if res, err = gs.GreetingByID(ctx, id); err != nil {
+			return err
+		}
+
+		var row GreetingRow
+
+		q := gs.Storage.DeleteStmt(GreetingsTable).Where(squirrel.Eq{gs.Storage.Col(&row, &row.ID): id})
+		if _, err = gs.Storage.Exec(ctx, q); err != nil {
+			return ctxd.WrapError(ctx, err, "failed to delete greeting")
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+// GreetingFinder implements service provider.
+func (gs *GreetingSaver) GreetingFinder() greeting.Finder {
+	if gs == nil {
+		panic("empty GreetingSaver")
+	}
+
+	return gs
+}
+
+// GreetingDeleter implements service provider.
+func (gs *GreetingSaver) GreetingDeleter() greeting.Deleter {
+	if gs == nil {
+		panic("empty GreetingSaver")
+	}
+
+	return gs
+}
+
+// GreetingLister implements service provider.
+func (gs *GreetingSaver) GreetingLister() greeting.Lister {
+	if gs == nil {
+		panic("empty GreetingSaver")
+	}
+
+	return gs
+}
+
+func (r GreetingRow) stored() greeting.Stored {
+	return greeting.Stored{
+		ID:        r.ID,
+		Message:   r.Message,
+		Name:      r.Name,
+		Locale:    r.Locale,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// likePrefix makes LIKE pattern with '!' as escape character, it is supported by both MySQL and SQLite
+// unlike backslash that has different meaning in string literals.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("id:" + strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, greeting.ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(string(b), "id:"))
+	if err != nil || !strings.HasPrefix(string(b), "id:") {
+		return 0, greeting.ErrInvalidCursor
+	}
+
+	return id, nil
+}
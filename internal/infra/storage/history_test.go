@@ -0,0 +1,109 @@
+package storage_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+)
+
+func TestGreetingSaver_ListGreetings(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	gs := &storage.GreetingSaver{Upstream: &greeting.SimpleMaker{Catalog: catalog}, Storage: st, Stats: stats.NoOp{}}
+	start := time.Now().Add(-time.Hour).UTC()
+
+	var rows []storage.GreetingRow
+
+	for i := 0; i < 25; i++ {
+		name := "user" + strconv.Itoa(i)
+		locale := "en-US"
+
+		if i%5 == 0 {
+			locale = "ru-RU"
+		}
+
+		rows = append(rows, storage.GreetingRow{
+			Message:   locale + " " + name,
+			Name:      name,
+			Locale:    locale,
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	rows = append(rows, storage.GreetingRow{Message: "Hello, us_er!", Name: "us_er", Locale: "en-US", CreatedAt: start})
+
+	_, err := st.Exec(ctx, st.InsertStmt(storage.GreetingsTable, rows, sqluct.InsertIgnore))
+	require.NoError(t, err)
+
+	// Paging through all greetings.
+	var (
+		ids    []int
+		cursor string
+		pages  int
+	)
+
+	for {
+		res, err := gs.ListGreetings(ctx, greeting.ListFilter{Limit: 10, Cursor: cursor})
+		require.NoError(t, err)
+		assert.Equal(t, 26, res.Total)
+
+		for _, item := range res.Items {
+			ids = append(ids, item.ID)
+		}
+
+		pages++
+
+		if res.NextCursor == "" {
+			break
+		}
+
+		cursor = res.NextCursor
+	}
+
+	assert.Equal(t, 3, pages)
+	require.Len(t, ids, 26)
+
+	for i := 1; i < len(ids); i++ {
+		assert.Greater(t, ids[i-1], ids[i])
+	}
+
+	// Filters.
+	res, err := gs.ListGreetings(ctx, greeting.ListFilter{Locale: "ru-RU", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 5, res.Total)
+	require.Len(t, res.Items, 2)
+	assert.Equal(t, "user20", res.Items[0].Name)
+	assert.NotEmpty(t, res.NextCursor)
+
+	res, err = gs.ListGreetings(ctx, greeting.ListFilter{NamePrefix: "user1", Limit: 20})
+	require.NoError(t, err)
+	assert.Equal(t, 11, res.Total)
+	assert.Empty(t, res.NextCursor)
+
+	// Underscore is not a wildcard.
+	res, err = gs.ListGreetings(ctx, greeting.ListFilter{NamePrefix: "us_", Limit: 20})
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Total)
+	assert.Equal(t, "us_er", res.Items[0].Name)
+
+	res, err = gs.ListGreetings(ctx, greeting.ListFilter{
+		CreatedAfter:  start.Add(10 * time.Minute),
+		CreatedBefore: start.Add(15 * time.Minute).UTC(),
+		Limit:         20,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5, res.Total)
+	assert.Equal(t, "user14", res.Items[0].Name)
+	assert.Equal(t, "user10", res.Items[4].Name)
+
+	_, err = gs.ListGreetings(ctx, greeting.ListFilter{Cursor: "foo"})
+	require.ErrorIs(t, err, greeting.ErrInvalidCursor)
+}
@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// LeasesTable is the name of the table.
+const LeasesTable = "build_leases"
+
+// LeaseRow describes database mapping.
+type LeaseRow struct {
+	Key       string `db:"lease_key"` // SHA-256 hex of lease key, so that long keys fit the column.
+	Owner     string `db:"owner"`
+	ExpiresAt int64  `db:"expires_at"` // Unix milliseconds.
+}
+
+// LeaseConfig controls cross-instance build leases.
+type LeaseConfig struct {
+	// Enabled enables build leases.
+	Enabled bool
+
+	// TTL is a lifetime of lease, lease of a crashed owner can be taken over after expiration.
+	TTL time.Duration `default:"10s"`
+
+	// Wait limits time to wait for a build of another instance, then value is built locally.
+	Wait time.Duration `default:"2s"`
+
+	// Poll is an interval to check lease while waiting.
+	Poll time.Duration `default:"50ms"`
+}
+
+// BuildLeases manages cross-instance build leases in database.
+type BuildLeases struct {
+	Storage *sqluct.Storage
+	Owner   string
+}
+
+// NewOwner makes a unique lease owner name of the instance.
+func NewOwner() string {
+	host, _ := os.Hostname() //nolint:errcheck // Hostname is only for readability.
+
+	b := make([]byte, 4)
+	_, _ = rand.Read(b) //nolint:errcheck // Random source does not fail.
+
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// leaseKey hashes lease key to a fixed length.
+func leaseKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(h[:])
+}
+
+// Acquire tries to take a lease, it succeeds if there is no lease or if existing lease has expired.
+func (bl *BuildLeases) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	key = leaseKey(key)
+	now := time.Now()
+	row := LeaseRow{Key: key, Owner: bl.Owner, ExpiresAt: now.Add(ttl).UnixMilli()}
+
+	res, err := bl.Storage.Exec(ctx, bl.Storage.InsertStmt(LeasesTable, row, sqluct.InsertIgnore))
+	if err != nil {
+		return false, ctxd.WrapError(ctx, err, "failed to insert lease")
+	}
+
+	if aff, err := res.RowsAffected(); err != nil || aff == 1 {
+		return aff == 1, err
+	}
+
+	// Taking over expired lease, condition on expiration makes it safe for concurrent takeovers.
+	q := bl.Storage.UpdateStmt(LeasesTable, row).
+		Where(bl.Storage.WhereEq(LeaseRow{Key: key}, sqluct.SkipZeroValues)).
+		Where(bl.Storage.Col(&row, &row.ExpiresAt)+" < ?", now.UnixMilli())
+
+	res, err = bl.Storage.Exec(ctx, q)
+	if err != nil {
+		return false, ctxd.WrapError(ctx, err, "failed to take over lease")
+	}
+
+	aff, err := res.RowsAffected()
+
+	return aff == 1, err
+}
+
+// Extend prolongs an active lease of the owner, it fails if lease has expired or is taken over.
+func (bl *BuildLeases) Extend(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	key = leaseKey(key)
+	now := time.Now()
+	row := LeaseRow{Key: key, Owner: bl.Owner, ExpiresAt: now.Add(ttl).UnixMilli()}
+
+	q := bl.Storage.UpdateStmt(LeasesTable, row).
+		Where(bl.Storage.WhereEq(LeaseRow{Key: key, Owner: bl.Owner}, sqluct.SkipZeroValues)).
+		Where(bl.Storage.Col(&row, &row.ExpiresAt)+" >= ?", now.UnixMilli())
+
+	res, err := bl.Storage.Exec(ctx, q)
+	if err != nil {
+		return false, ctxd.WrapError(ctx, err, "failed to extend lease")
+	}
+
+	aff, err := res.RowsAffected()
+
+	return aff == 1, err
+}
+
+// Release removes a lease of the owner.
+func (bl *BuildLeases) Release(ctx context.Context, key string) error {
+	q := bl.Storage.DeleteStmt(LeasesTable).
+		Where(bl.Storage.WhereEq(LeaseRow{Key: leaseKey(key), Owner: bl.Owner}, sqluct.SkipZeroValues))
+
+	if _, err := bl.Storage.Exec(ctx, q); err != nil {
+		return ctxd.WrapError(ctx, err, "failed to release lease")
+	}
+
+	return nil
+}
+
+// Held checks if there is an active lease.
+func (bl *BuildLeases) Held(ctx context.Context, key string) (bool, error) {
+	var rows []LeaseRow
+
+	q := bl.Storage.SelectStmt(LeasesTable, LeaseRow{}).
+		Where(bl.Storage.WhereEq(LeaseRow{Key: leaseKey(key)}, sqluct.SkipZeroValues)).
+		Where("expires_at >= ?", time.Now().UnixMilli())
+
+	if err := bl.Storage.Select(ctx, q, &rows); err != nil {
+		return false, ctxd.WrapError(ctx, err, "failed to check lease")
+	}
+
+	return len(rows) > 0, nil
+}
+
+// LeasedGreetingMaker makes sure only one instance builds a greeting at a time.
+//
+// Other instances wait for the lease to be released and use the greeting stored by the owner.
+// If the lease is not released within LeaseConfig.Wait, greeting is built locally.
+type LeasedGreetingMaker struct {
+	Upstream greeting.Maker
+	Leases   *BuildLeases
+	Config   LeaseConfig
+	Stats    stats.Tracker
+
+	// Writer of Upstream is flushed before lease is released, so that waiters find the stored greeting, optional.
+	Writer *GreetingWriter
+}
+
+// Hello makes a greeting under build lease.
+func (lm *LeasedGreetingMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	key := "greeting:" + params.Locale + ":" + params.Name
+	start := time.Now()
+	deadline := start.Add(lm.Config.Wait)
+
+	for {
+		acquired, err := lm.Leases.Acquire(ctx, key, lm.Config.TTL)
+		if err != nil {
+			return greeting.Greeting{}, err
+		}
+
+		if acquired {
+			lm.Stats.Add(ctx, "build_lease", 1, "result", "acquired")
+
+			return lm.build(ctx, key, params)
+		}
+
+		held, err := lm.wait(ctx, key, deadline)
+		if err != nil {
+			return greeting.Greeting{}, err
+		}
+
+		if held {
+			lm.Stats.Add(ctx, "build_lease", 1, "result", "timeout")
+
+			return lm.Upstream.Hello(ctx, params)
+		}
+
+		// Owner has released the lease, its result is available in database.
+		g, found, err := findGreeting(ctx, lm.Leases.Storage, params)
+		if err != nil {
+			return greeting.Greeting{}, err
+		}
+
+		if found {
+			lm.Stats.Add(ctx, "build_lease", 1, "result", "shared")
+			lm.Stats.Add(ctx, "build_lease_wait_seconds", time.Since(start).Seconds())
+
+			return g, nil
+		}
+
+		// Owner has failed, trying to build again.
+	}
+}
+
+func (lm *LeasedGreetingMaker) build(ctx context.Context, key string, params greeting.Params) (greeting.Greeting, error) {
+	defer func() {
+		// Detached context to release lease of a cancelled request.
+		if err := lm.Leases.Release(context.WithoutCancel(ctx), key); err != nil {
+			lm.Stats.Add(ctx, "build_lease_release_failed", 1)
+		}
+	}()
+
+	g, err := lm.Upstream.Hello(ctx, params)
+
+	if err == nil && lm.Writer != nil {
+		if err := lm.Writer.Flush(ctx); err != nil {
+			lm.Stats.Add(ctx, "build_lease_flush_failed", 1)
+		}
+	}
+
+	return g, err
+}
+
+// wait polls lease until it is released or deadline is reached, returns true if lease is still held.
+func (lm *LeasedGreetingMaker) wait(ctx context.Context, key string, deadline time.Time) (bool, error) {
+	poll := lm.Config.Poll
+	if poll <= 0 {
+		poll = 50 * time.Millisecond
+	}
+
+	for {
+		held, err := lm.Leases.Held(ctx, key)
+		if err != nil || !held {
+			return held, err
+		}
+
+		if time.Now().Add(poll).After(deadline) {
+			return true, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// GreetingMaker implements service provider.
+func (lm *LeasedGreetingMaker) GreetingMaker() greeting.Maker {
+	if lm == nil {
+		panic("empty LeasedGreetingMaker")
+	}
+
+	return lm
+}
+
+func findGreeting(ctx context.Context, st *sqluct.Storage, params greeting.Params) (greeting.Greeting, bool, error) {
+	row, err := greetingByParams(ctx, st, params)
+	if err != nil {
+		if errors.Is(err, greeting.ErrNotFound) {
+			return greeting.Greeting{}, false, nil
+		}
+
+		return greeting.Greeting{}, false, err
+	}
+
+	return row.greeting(), true, nil
+}
@@ -0,0 +1,192 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bool64/brick/database"
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/locales"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+	"github.com/vearutop/cache-story/internal/infra/storage/sqlite"
+	_ "modernc.org/sqlite" // SQLite3 driver.
+)
+
+// catalog has locales of application.
+var catalog = func() *greeting.Catalog {
+	c, err := greeting.LoadCatalog(locales.Files)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}()
+
+func newStorage(t *testing.T) *sqluct.Storage {
+	t.Helper()
+
+	st, err := database.SetupStorageDSN(database.Config{
+		DriverName:      "sqlite",
+		DSN:             filepath.Join(t.TempDir(), "db.sqlite"),
+		MaxOpen:         1,
+		ApplyMigrations: true,
+	}, ctxd.NoOpLogger{}, stats.NoOp{}, sqlite.Migrations)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, st.DB().Close())
+	})
+
+	return st
+}
+
+func TestBuildLeases(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+
+	a := storage.BuildLeases{Storage: st, Owner: "a"}
+	b := storage.BuildLeases{Storage: st, Owner: "b"}
+
+	ok, err := a.Acquire(ctx, "k", 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = b.Acquire(ctx, "k", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	held, err := b.Held(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	// Lease of a crashed owner expires and can be taken over.
+	time.Sleep(60 * time.Millisecond)
+
+	held, err = b.Held(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	ok, err = b.Acquire(ctx, "k", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Only owner can extend lease.
+	ok, err = a.Extend(ctx, "k", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = b.Extend(ctx, "k", 2*time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Former owner can not release lease that is taken over.
+	require.NoError(t, a.Release(ctx, "k"))
+
+	held, err = a.Held(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	require.NoError(t, b.Release(ctx, "k"))
+
+	held, err = a.Held(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	// Long keys are stored as fixed length hashes.
+	long := strings.Repeat("k", 1000)
+
+	ok, err = a.Acquire(ctx, long, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	held, err = b.Held(ctx, long)
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	var keys []string
+
+	require.NoError(t, st.Select(ctx, st.QueryBuilder().Select("lease_key").From(storage.LeasesTable), &keys))
+	require.Len(t, keys, 1)
+	assert.Len(t, keys[0], 64)
+}
+
+type slowMaker struct {
+	calls int64
+}
+
+func (s *slowMaker) Hello(ctx context.Context, params greeting.Params) (greeting.Greeting, error) {
+	atomic.AddInt64(&s.calls, 1)
+	time.Sleep(100 * time.Millisecond)
+
+	return (&greeting.SimpleMaker{Catalog: catalog}).Hello(ctx, params)
+}
+
+func TestLeasedGreetingMaker_Hello(t *testing.T) {
+	testLeasedGreetingMaker(t, false)
+}
+
+func TestLeasedGreetingMaker_Hello_writer(t *testing.T) {
+	testLeasedGreetingMaker(t, true)
+}
+
+func testLeasedGreetingMaker(t *testing.T, withWriter bool) {
+	t.Helper()
+
+	ctx := context.Background()
+	st := newStorage(t)
+	upstream := &slowMaker{}
+	cfg := storage.LeaseConfig{TTL: time.Second, Wait: time.Second, Poll: 10 * time.Millisecond}
+
+	// Instances share database.
+	var instances []*storage.LeasedGreetingMaker
+
+	for _, owner := range []string{"a", "b", "c"} {
+		gs := &storage.GreetingSaver{Upstream: upstream, Storage: st, Stats: stats.NoOp{}}
+
+		// Buffered greeting would not be found by waiters without flush.
+		if withWriter {
+			gs.Writer = storage.NewGreetingWriter(st, storage.BatchConfig{Size: 100, Interval: time.Minute},
+				ctxd.NoOpLogger{}, stats.NoOp{})
+
+			t.Cleanup(func() {
+				require.NoError(t, gs.Writer.Close(ctx))
+			})
+		}
+
+		instances = append(instances, &storage.LeasedGreetingMaker{
+			Upstream: gs,
+			Leases:   &storage.BuildLeases{Storage: st, Owner: owner},
+			Config:   cfg,
+			Stats:    stats.NoOp{},
+			Writer:   gs.Writer,
+		})
+	}
+
+	wg := sync.WaitGroup{}
+
+	for _, lm := range instances {
+		wg.Add(1)
+
+		go func(lm *storage.LeasedGreetingMaker) {
+			defer wg.Done()
+
+			g, err := lm.Hello(ctx, greeting.Params{Name: "Jane", Locale: "en-US"})
+			assert.NoError(t, err)
+			assert.Equal(t, "Hello, Jane!", g.Message)
+		}(lm)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&upstream.calls))
+}
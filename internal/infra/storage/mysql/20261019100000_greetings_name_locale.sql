@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `greetings`
+    ADD COLUMN `name`   VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN `locale` VARCHAR(16)  NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `greetings`
+    DROP COLUMN `name`,
+    DROP COLUMN `locale`;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `build_leases`
+(
+    `lease_key`  VARCHAR(255) NOT NULL,
+    `owner`      VARCHAR(64)  NOT NULL,
+    `expires_at` BIGINT       NOT NULL,
+    PRIMARY KEY (`lease_key`)
+) ENGINE = InnoDB
+  DEFAULT CHARACTER SET = utf8;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `build_leases`;
+-- +goose StatementEnd
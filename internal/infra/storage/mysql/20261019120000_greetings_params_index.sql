@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+UPDATE `greetings`
+SET `locale` = 'en-US',
+    `name`   = SUBSTRING(`message`, 8, CHAR_LENGTH(`message`) - 8)
+WHERE `locale` = ''
+  AND `message` LIKE 'Hello, %!';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE `greetings`
+SET `locale` = 'ru-RU',
+    `name`   = SUBSTRING(`message`, 9, CHAR_LENGTH(`message`) - 9)
+WHERE `locale` = ''
+  AND `message` LIKE 'Привет, %!';
+-- +goose StatementEnd
+
+-- Greetings are rebuildable, rows with unrecognized messages are removed to keep params unique,
+-- removed rows are not restored by Down.
+-- +goose StatementBegin
+DELETE
+FROM `greetings`
+WHERE `locale` = '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX `greetings_name_locale` ON `greetings` (`name`, `locale`);
+-- +goose StatementEnd
+
+-- Greetings are unique by params, locales can have the same message, for example pt and pt-BR.
+-- +goose StatementBegin
+DROP INDEX `message` ON `greetings`;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Unique message index is not restored, because greetings of different locales may have the same message.
+-- +goose StatementBegin
+DROP INDEX `greetings_name_locale` ON `greetings`;
+-- +goose StatementEnd
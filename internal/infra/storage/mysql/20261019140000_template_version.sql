@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `greeting_templates`
+    ADD COLUMN `version` INTEGER NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE `greetings`
+    ADD COLUMN `template_version` INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `greetings`
+    DROP COLUMN `template_version`;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE `greeting_templates`
+    DROP COLUMN `version`;
+-- +goose StatementEnd
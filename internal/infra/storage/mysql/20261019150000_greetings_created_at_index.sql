@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX `greetings_created_at` ON `greetings` (`created_at`);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX `greetings_created_at` ON `greetings`;
+-- +goose StatementEnd
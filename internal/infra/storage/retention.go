@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+)
+
+// RetentionConfig controls background cleanup of old greetings.
+type RetentionConfig struct {
+	// MaxAge is an age of greetings to delete, zero disables retention.
+	MaxAge time.Duration `split_words:"true"`
+
+	// Interval is a time between retention runs.
+	Interval time.Duration `default:"1h"`
+
+	// Jitter adds random delay up to the value to every run, so that replicas do not start together.
+	Jitter time.Duration `default:"5m"`
+
+	// BatchSize limits number of rows deleted with a single statement.
+	BatchSize int `split_words:"true" default:"1000"`
+
+	// RateLimit limits number of deleted batches per second, zero disables rate limit.
+	RateLimit float64 `split_words:"true" default:"10"`
+
+	// LeaseTTL is a lifetime of lease that prevents concurrent runs, lease is extended after every batch.
+	LeaseTTL time.Duration `split_words:"true" default:"1m"`
+}
+
+// retentionLease is a lease key of retention run.
+const retentionLease = "retention:greetings"
+
+// Retention deletes old greetings in batches.
+//
+// A run takes a lease, so that only one instance deletes rows at a time,
+// instances that fail to take a lease skip the run.
+type Retention struct {
+	Storage *sqluct.Storage
+	Leases  *BuildLeases
+	Config  RetentionConfig
+	Logger  ctxd.Logger
+	Stats   stats.Tracker
+}
+
+// RunSchedule runs retention every Config.Interval with random Config.Jitter until context is done.
+func (r *Retention) RunSchedule(ctx context.Context) {
+	if r.Config.MaxAge <= 0 || r.Config.Interval <= 0 {
+		return
+	}
+
+	for {
+		delay := r.Config.Interval
+		if r.Config.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(r.Config.Jitter))) //nolint:gosec // Weak randomness is fine here.
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if _, err := r.Run(ctx); err != nil {
+			r.Logger.Warn(ctx, "greetings retention failed", "error", err)
+		}
+	}
+}
+
+// Run deletes greetings older than Config.MaxAge and returns number of deleted rows.
+func (r *Retention) Run(ctx context.Context) (int, error) {
+	if r.Config.MaxAge <= 0 {
+		return 0, nil
+	}
+
+	acquired, err := r.Leases.Acquire(ctx, retentionLease, r.Config.LeaseTTL)
+	if err != nil {
+		return 0, err
+	}
+
+	if !acquired {
+		r.Stats.Add(ctx, "retention_runs", 1, "result", "skipped")
+
+		return 0, nil
+	}
+
+	defer func() {
+		// Detached context to release lease of a cancelled run.
+		if err := r.Leases.Release(context.WithoutCancel(ctx), retentionLease); err != nil {
+			r.Logger.Warn(ctx, "failed to release retention lease", "error", err)
+		}
+	}()
+
+	start := time.Now()
+
+	deleted, err := r.deleteBefore(ctx, start.Add(-r.Config.MaxAge))
+
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+
+	r.Stats.Add(ctx, "retention_runs", 1, "result", result)
+	r.Stats.Set(ctx, "retention_seconds", time.Since(start).Seconds())
+	r.Logger.Important(ctx, "greetings retention finished",
+		"deleted", deleted, "result", result, "elapsed", time.Since(start).String())
+
+	return deleted, err
+}
+
+func (r *Retention) deleteBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	batchSize := r.Config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	var tick <-chan time.Time
+
+	if r.Config.RateLimit > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.Config.RateLimit))
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	deleted := 0
+
+	for {
+		found, n, err := r.deleteBatch(ctx, cutoff, batchSize)
+		deleted += n
+
+		if err != nil || found < batchSize {
+			return deleted, err
+		}
+
+		// Lease is extended, so that a long run is not taken over by another instance.
+		extended, err := r.Leases.Extend(ctx, retentionLease, r.Config.LeaseTTL)
+		if err != nil {
+			return deleted, err
+		}
+
+		if !extended {
+			return deleted, ctxd.NewError(ctx, "retention lease lost")
+		}
+
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return deleted, ctx.Err()
+			case <-tick:
+			}
+		}
+	}
+}
+
+// deleteBatch deletes a batch of greetings created before cutoff,
+// returns number of found and deleted rows.
+//
+// Rows are found and deleted with separate statements, because MySQL does not support
+// LIMIT in DELETE with subquery, and SQLite does not support LIMIT in DELETE by default.
+func (r *Retention) deleteBatch(ctx context.Context, cutoff time.Time, batchSize int) (int, int, error) {
+	var (
+		row GreetingRow
+		ids []int
+	)
+
+	id := r.Storage.Col(&row, &row.ID)
+
+	q := r.Storage.QueryBuilder().Select(id).From(GreetingsTable).
+		Where(squirrel.Lt{r.Storage.Col(&row, &row.CreatedAt): createdAtArg(r.Storage, cutoff)}).
+		OrderBy(id).
+		Limit(uint64(batchSize))
+
+	if err := r.Storage.Select(ctx, q, &ids); err != nil {
+		return 0, 0, ctxd.WrapError(ctx, err, "failed to find old greetings")
+	}
+
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	res, err := r.Storage.Exec(ctx, r.Storage.DeleteStmt(GreetingsTable).Where(squirrel.Eq{id: ids}))
+	if err != nil {
+		return len(ids), 0, ctxd.WrapError(ctx, err, "failed to delete old greetings")
+	}
+
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return len(ids), 0, ctxd.WrapError(ctx, err, "failed to count deleted greetings")
+	}
+
+	r.Stats.Add(ctx, "retention_deleted_rows", float64(aff))
+
+	return len(ids), int(aff), nil
+}
@@ -0,0 +1,114 @@
+package storage_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+)
+
+func TestRetention_Run(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	tr := &stats.TrackerMock{}
+	now := time.Now().UTC()
+
+	var rows []storage.GreetingRow
+
+	for i := 0; i < 12; i++ {
+		age := 2 * time.Hour
+		if i%4 == 0 {
+			age = time.Minute
+		}
+
+		name := "user" + strconv.Itoa(i)
+		rows = append(rows, storage.GreetingRow{Message: "Hello, " + name + "!", Name: name, Locale: "en-US", CreatedAt: now.Add(-age)})
+	}
+
+	_, err := st.Exec(ctx, st.InsertStmt(storage.GreetingsTable, rows, sqluct.InsertIgnore))
+	require.NoError(t, err)
+
+	r := &storage.Retention{
+		Storage: st,
+		Leases:  &storage.BuildLeases{Storage: st, Owner: "a"},
+		Config:  storage.RetentionConfig{MaxAge: time.Hour, BatchSize: 4, LeaseTTL: time.Minute},
+		Logger:  ctxd.NoOpLogger{},
+		Stats:   tr,
+	}
+
+	// Run is skipped while another instance holds the lease.
+	other := &storage.BuildLeases{Storage: st, Owner: "b"}
+	ok, err := other.Acquire(ctx, "retention:greetings", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	deleted, err := r.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.Equal(t, 1, tr.Int("retention_runs", "result", "skipped"))
+
+	require.NoError(t, other.Release(ctx, "retention:greetings"))
+
+	// Old rows are deleted in batches.
+	deleted, err = r.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 9, deleted)
+	assert.Equal(t, 9, tr.Int("retention_deleted_rows"))
+	assert.Equal(t, 1, tr.Int("retention_runs", "result", "ok"))
+
+	var names []string
+
+	require.NoError(t, st.Select(ctx, st.QueryBuilder().Select("name").From(storage.GreetingsTable).OrderBy("id"), &names))
+	assert.Equal(t, []string{"user0", "user4", "user8"}, names)
+
+	// Lease is released after the run.
+	held, err := other.Held(ctx, "retention:greetings")
+	require.NoError(t, err)
+	assert.False(t, held)
+}
+
+func TestRetention_Run_defaultTimestamp(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	now := time.Now().UTC()
+
+	rows := []storage.GreetingRow{
+		{Message: "Hello, old!", Name: "old", Locale: "en-US", CreatedAt: now.Add(-2 * time.Hour)},
+		{Message: "Hello, new!", Name: "new", Locale: "en-US", CreatedAt: now.Add(-time.Minute)},
+	}
+
+	_, err := st.Exec(ctx, st.InsertStmt(storage.GreetingsTable, rows, sqluct.InsertIgnore))
+	require.NoError(t, err)
+
+	// Rows written by database default have a different text format in SQLite.
+	_, err = st.DB().Exec("INSERT INTO " + storage.GreetingsTable + " (message, name, locale) VALUES ('Hello, default-new!', 'default-new', 'en-US')")
+	require.NoError(t, err)
+
+	_, err = st.DB().Exec("INSERT INTO " + storage.GreetingsTable +
+		" (message, name, locale, created_at) VALUES ('Hello, default-old!', 'default-old', 'en-US', datetime('now', '-2 hours'))")
+	require.NoError(t, err)
+
+	r := &storage.Retention{
+		Storage: st,
+		Leases:  &storage.BuildLeases{Storage: st, Owner: "a"},
+		Config:  storage.RetentionConfig{MaxAge: time.Hour, BatchSize: 10, LeaseTTL: time.Minute},
+		Logger:  ctxd.NoOpLogger{},
+		Stats:   stats.NoOp{},
+	}
+
+	deleted, err := r.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	var names []string
+
+	require.NoError(t, st.Select(ctx, st.QueryBuilder().Select("name").From(storage.GreetingsTable).OrderBy("id"), &names))
+	assert.Equal(t, []string{"new", "default-new"}, names)
+}
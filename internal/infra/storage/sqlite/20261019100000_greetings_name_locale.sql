@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `greetings` ADD COLUMN `name` VARCHAR(255) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE `greetings` ADD COLUMN `locale` VARCHAR(16) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `greetings` DROP COLUMN `locale`;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE `greetings` DROP COLUMN `name`;
+-- +goose StatementEnd
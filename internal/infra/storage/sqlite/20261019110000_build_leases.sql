@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE build_leases
+(
+    `lease_key`  VARCHAR(255) NOT NULL PRIMARY KEY,
+    `owner`      VARCHAR(64)  NOT NULL,
+    `expires_at` BIGINT       NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `build_leases`;
+-- +goose StatementEnd
@@ -0,0 +1,61 @@
+-- +goose Up
+-- +goose StatementBegin
+UPDATE `greetings`
+SET `locale` = 'en-US',
+    `name`   = substr(`message`, 8, length(`message`) - 8)
+WHERE `locale` = ''
+  AND `message` LIKE 'Hello, %!';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE `greetings`
+SET `locale` = 'ru-RU',
+    `name`   = substr(`message`, 9, length(`message`) - 9)
+WHERE `locale` = ''
+  AND `message` LIKE 'Привет, %!';
+-- +goose StatementEnd
+
+-- Greetings are rebuildable, rows with unrecognized messages are removed to keep params unique,
+-- removed rows are not restored by Down.
+-- +goose StatementBegin
+DELETE
+FROM `greetings`
+WHERE `locale` = '';
+-- +goose StatementEnd
+
+-- Greetings are unique by params, locales can have the same message, for example pt and pt-BR.
+-- SQLite can not drop inline unique constraint, so table is rebuilt without it.
+-- +goose StatementBegin
+CREATE TABLE `greetings_params`
+(
+    `id`         INTEGER PRIMARY KEY,
+    `created_at` DATETIME     NOT NULL DEFAULT current_timestamp,
+    `message`    VARCHAR(255) NOT NULL,
+    `name`       VARCHAR(255) NOT NULL DEFAULT '',
+    `locale`     VARCHAR(16)  NOT NULL DEFAULT ''
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO `greetings_params` (`id`, `created_at`, `message`, `name`, `locale`)
+SELECT `id`, `created_at`, `message`, `name`, `locale`
+FROM `greetings`;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE `greetings`;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE `greetings_params` RENAME TO `greetings`;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX `greetings_name_locale` ON `greetings` (`name`, `locale`);
+-- +goose StatementEnd
+
+-- +goose Down
+-- Unique message constraint is not restored, because greetings of different locales may have the same message.
+-- +goose StatementBegin
+DROP INDEX `greetings_name_locale`;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `greeting_templates`
+(
+    `locale`     VARCHAR(32)  NOT NULL PRIMARY KEY,
+    `template`   VARCHAR(255) NOT NULL,
+    `updated_at` DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO `greeting_templates` (`locale`, `template`)
+VALUES ('en-US', 'Hello, {name}!'),
+       ('ru-RU', 'Привет, {name}!');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `greeting_templates`;
+-- +goose StatementEnd
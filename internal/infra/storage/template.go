@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bool64/ctxd"
+	"github.com/bool64/sqluct"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// TemplatesTable is the name of the table.
+const TemplatesTable = "greeting_templates"
+
+// TemplateRow describes database mapping.
+type TemplateRow struct {
+	Locale    string    `db:"locale"`
+	Text      string    `db:"template"`
+	Version   int       `db:"version"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// TemplateStore keeps greeting templates in database.
+type TemplateStore struct {
+	Storage *sqluct.Storage
+
+	// Catalog defines locales that fall back to changed template, optional.
+	Catalog *greeting.Catalog
+}
+
+// TemplateByLocale returns greeting template.
+func (ts *TemplateStore) TemplateByLocale(ctx context.Context, locale string) (greeting.Template, error) {
+	var row TemplateRow
+
+	q := ts.Storage.SelectStmt(TemplatesTable, row).
+		Where(squirrel.Eq{ts.Storage.Col(&row, &row.Locale): locale})
+
+	if err := ts.Storage.Select(ctx, q, &row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return greeting.Template{}, greeting.ErrTemplateNotFound
+		}
+
+		return greeting.Template{}, ctxd.WrapError(ctx, err, "failed to find greeting template")
+	}
+
+	return row.template(), nil
+}
+
+// ListTemplates returns all greeting templates ordered by locale.
+func (ts *TemplateStore) ListTemplates(ctx context.Context) ([]greeting.Template, error) {
+	var (
+		row  TemplateRow
+		rows []TemplateRow
+	)
+
+	q := ts.Storage.SelectStmt(TemplatesTable, row).OrderBy(ts.Storage.Col(&row, &row.Locale))
+
+	if err := ts.Storage.Select(ctx, q, &rows); err != nil {
+		return nil, ctxd.WrapError(ctx, err, "failed to list greeting templates")
+	}
+
+	res := make([]greeting.Template, 0, len(rows))
+	for _, r := range rows {
+		res = append(res, r.template())
+	}
+
+	return res, nil
+}
+
+// SaveTemplate creates or updates greeting template and removes stored greetings made with previous version.
+func (ts *TemplateStore) SaveTemplate(ctx context.Context, t greeting.Template) (greeting.Template, error) {
+	if err := t.Validate(); err != nil {
+		return t, err
+	}
+
+	row := TemplateRow{
+		Locale:    t.Locale,
+		Text:      t.Text,
+		Version:   1,
+		UpdatedAt: time.Now(),
+	}
+
+	err := ts.Storage.InTx(ctx, func(ctx context.Context) error {
+		prev, err := ts.TemplateByLocale(ctx, t.Locale)
+
+		switch {
+		case errors.Is(err, greeting.ErrTemplateNotFound):
+			if _, err = ts.Storage.Exec(ctx, ts.Storage.InsertStmt(TemplatesTable, row)); err != nil {
+				return ctxd.WrapError(ctx, err, "failed to insert greeting template")
+			}
+		case err != nil:
+			return err
+		default:
+			row.Version = prev.Version + 1
+
+			q := ts.Storage.UpdateStmt(TemplatesTable, row).
+				Where(squirrel.Eq{ts.Storage.Col(&row, &row.Locale): t.Locale})
+
+			if _, err = ts.Storage.Exec(ctx, q); err != nil {
+				return ctxd.WrapError(ctx, err, "failed to update greeting template")
+			}
+		}
+
+		return ts.deleteGreetings(ctx, t.Locale)
+	})
+
+	return row.template(), err
+}
+
+// DeleteTemplate removes greeting template with stored greetings of its locale and returns removed template.
+func (ts *TemplateStore) DeleteTemplate(ctx context.Context, locale string) (greeting.Template, error) {
+	var res greeting.Template
+
+	err := ts.Storage.InTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if res, err = ts.TemplateByLocale(ctx, locale); err != nil {
+			return err
+		}
+
+		var row TemplateRow
+
+		q := ts.Storage.DeleteStmt(TemplatesTable).Where(squirrel.Eq{ts.Storage.Col(&row, &row.Locale): locale})
+		if _, err = ts.Storage.Exec(ctx, q); err != nil {
+			return ctxd.WrapError(ctx, err, "failed to delete greeting template")
+		}
+
+		return ts.deleteGreetings(ctx, locale)
+	})
+
+	return res, err
+}
+
+// deleteGreetings removes stored greetings of a locale and of locales that fall back to it,
+// so that they are made again with actual template.
+func (ts *TemplateStore) deleteGreetings(ctx context.Context, locale string) error {
+	var row GreetingRow
+
+	locales := []string{locale}
+	if ts.Catalog != nil {
+		locales = ts.Catalog.Dependents(locale)
+	}
+
+	q := ts.Storage.DeleteStmt(GreetingsTable).Where(squirrel.Eq{ts.Storage.Col(&row, &row.Locale): locales})
+	if _, err := ts.Storage.Exec(ctx, q); err != nil {
+		return ctxd.WrapError(ctx, err, "failed to delete greetings of template")
+	}
+
+	return nil
+}
+
+// TemplateStorage implements service provider.
+func (ts *TemplateStore) TemplateStorage() greeting.TemplateStorage {
+	if ts == nil {
+		panic("empty TemplateStore")
+	}
+
+	return ts
+}
+
+func (r TemplateRow) template() greeting.Template {
+	return greeting.Template{
+		Locale:    r.Locale,
+		Text:      r.Text,
+		Version:   r.Version,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
@@ -0,0 +1,81 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bool64/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+	"github.com/vearutop/cache-story/internal/infra/storage"
+)
+
+func TestTemplateStore(t *testing.T) {
+	ctx := context.Background()
+	st := newStorage(t)
+	ts := &storage.TemplateStore{Storage: st}
+	gs := &storage.GreetingSaver{Upstream: &greeting.TemplateMaker{Templates: ts}, Storage: st, Stats: stats.NoOp{}}
+
+	// Templates are seeded by migration.
+	list, err := ts.ListTemplates(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "en-US", list[0].Locale)
+	assert.Equal(t, "Hello, {name}!", list[0].Text)
+	assert.Equal(t, "Привет, {name}!", list[1].Text)
+	assert.NotZero(t, list[1].UpdatedAt)
+	assert.Equal(t, 1, list[0].Version)
+
+	for _, p := range []greeting.Params{{Name: "a", Locale: "en-US"}, {Name: "b", Locale: "en-US"}, {Name: "a", Locale: "ru-RU"}} {
+		_, err = gs.Hello(ctx, p)
+		require.NoError(t, err)
+	}
+
+	// Updating template removes stored greetings of its locale.
+	_, err = ts.SaveTemplate(ctx, greeting.Template{Locale: "en-US", Text: "Hi!"})
+	require.ErrorIs(t, err, greeting.ErrInvalidTemplate)
+
+	tpl, err := ts.SaveTemplate(ctx, greeting.Template{Locale: "en-US", Text: "Hi, {name}!"})
+	require.NoError(t, err)
+	assert.NotZero(t, tpl.UpdatedAt)
+	assert.Equal(t, 2, tpl.Version)
+	assert.Equal(t, 1, countGreetings(t, st))
+
+	g, err := gs.Hello(ctx, greeting.Params{Name: "a", Locale: "en-US"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi, a!", g.Message)
+	assert.Equal(t, "en-US", g.Locale)
+	assert.Equal(t, 2, g.TemplateVersion)
+	assert.NotZero(t, g.ID)
+	assert.NotZero(t, g.CreatedAt)
+
+	row, err := gs.GreetingByParams(ctx, greeting.Params{Name: "a", Locale: "en-US"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi, a!", row.Message)
+	assert.Equal(t, g.ID, row.ID)
+	assert.Equal(t, 2, row.TemplateVersion)
+
+	// Creating template of a new locale.
+	_, err = gs.Hello(ctx, greeting.Params{Name: "a", Locale: "de-DE"})
+	require.ErrorIs(t, err, greeting.ErrTemplateNotFound)
+
+	_, err = ts.SaveTemplate(ctx, greeting.Template{Locale: "de-DE", Text: "Hallo, {name}!"})
+	require.NoError(t, err)
+
+	g, err = gs.Hello(ctx, greeting.Params{Name: "a", Locale: "de-DE"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hallo, a!", g.Message)
+
+	// Deleting template.
+	tpl, err = ts.DeleteTemplate(ctx, "ru-RU")
+	require.NoError(t, err)
+	assert.Equal(t, "Привет, {name}!", tpl.Text)
+	assert.Equal(t, 2, countGreetings(t, st))
+
+	_, err = ts.DeleteTemplate(ctx, "ru-RU")
+	require.ErrorIs(t, err, greeting.ErrTemplateNotFound)
+
+	_, err = ts.TemplateByLocale(ctx, "ru-RU")
+	require.ErrorIs(t, err, greeting.ErrTemplateNotFound)
+}
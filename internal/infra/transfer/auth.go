@@ -0,0 +1,89 @@
+package transfer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tokenPrefix = "Bearer "
+
+var errUnauthorized = errors.New("invalid or expired cache transfer token")
+
+// token makes a signed token that authorizes access to scope until expiration.
+func token(secret, scope string, expireAt time.Time) string {
+	exp := strconv.FormatInt(expireAt.Unix(), 10)
+
+	return exp + "." + sign(secret, exp+"."+scope)
+}
+
+// verifyToken checks token signature and expiration.
+func verifyToken(secret, scope, tok string, maxTTL time.Duration) error {
+	exp, sig, found := strings.Cut(tok, ".")
+	if !found {
+		return errUnauthorized
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(sign(secret, exp+"."+scope))) {
+		return errUnauthorized
+	}
+
+	ts, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return errUnauthorized
+	}
+
+	expireAt := time.Unix(ts, 0)
+	now := time.Now()
+
+	// Tokens that live longer than allowed are rejected too.
+	if expireAt.Before(now) || expireAt.After(now.Add(maxTTL)) {
+		return errUnauthorized
+	}
+
+	return nil
+}
+
+func sign(secret, msg string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	_, _ = h.Write([]byte(msg)) //nolint:errcheck // Hash never fails to write.
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authorize sets request token if secret is configured.
+func (t *Transfer) authorize(req *http.Request, scope string) {
+	if t.Config.Secret == "" {
+		return
+	}
+
+	req.Header.Set("Authorization", tokenPrefix+token(t.Config.Secret, scope, time.Now().Add(t.tokenTTL())))
+}
+
+// authenticate checks request token if secret is configured.
+func (t *Transfer) authenticate(r *http.Request, scope string) error {
+	if t.Config.Secret == "" {
+		return nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, tokenPrefix) {
+		return errUnauthorized
+	}
+
+	// Small allowance for clock skew between instances.
+	return verifyToken(t.Config.Secret, scope, strings.TrimPrefix(auth, tokenPrefix), t.tokenTTL()+5*time.Second)
+}
+
+func (t *Transfer) tokenTTL() time.Duration {
+	if t.Config.TokenTTL == 0 {
+		return time.Minute
+	}
+
+	return t.Config.TokenTTL
+}
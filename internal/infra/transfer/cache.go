@@ -0,0 +1,379 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"hash/fnv"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/bool64/cache"
+)
+
+// Cache is a transferable cache backend.
+type Cache interface {
+	cache.Walker
+	cache.Restorer
+	Len() int
+
+	// Dump writes entries that match filter in encoding/gob format and returns number of written entries.
+	Dump(w io.Writer, f Filter) (int, error)
+
+	// Merge reads entries in encoding/gob format and stores those that expire later than local ones,
+	// number of stored entries is returned.
+	Merge(r io.Reader) (int, error)
+
+	// Digest returns per-bucket digests of keys and values.
+	Digest(buckets int) ([]uint64, error)
+
+	// Expirations returns expiration times by key hash for entries of selected buckets,
+	// deleted keys have expiration times of their tombstones.
+	Expirations(buckets int, selected []int) (map[uint64]int64, error)
+}
+
+// Filter selects cache entries to export.
+type Filter struct {
+	// MinTTL skips entries that expire sooner.
+	MinTTL time.Duration
+
+	// Limit is a maximum number of entries to export, zero means no limit.
+	// With limit, most recently or most frequently used entries come first,
+	// depending on cache eviction strategy.
+	Limit int
+
+	// Buckets is a number of key buckets for Selected.
+	Buckets int
+
+	// Selected limits export to entries of listed buckets if Buckets is set.
+	Selected []int
+
+	// Known is a map of expiration times by key hash of entries available to importer,
+	// entries that do not expire later are skipped.
+	Known map[uint64]int64
+}
+
+// matcher makes a function that checks if entry passes bucket and known entries filter.
+func (f Filter) matcher() func(key []byte, exp int64) bool {
+	if f.Buckets <= 0 {
+		return func([]byte, int64) bool { return true }
+	}
+
+	inBucket := bucketMatcher(f.Buckets, f.Selected)
+
+	return func(key []byte, exp int64) bool {
+		kh := keyHash(key)
+		if !inBucket(kh) {
+			return false
+		}
+
+		known, ok := f.Known[kh]
+
+		return !ok || newer(exp, known)
+	}
+}
+
+func bucketMatcher(buckets int, selected []int) func(kh uint64) bool {
+	sel := make(map[uint64]bool, len(selected))
+	for _, b := range selected {
+		sel[uint64(b)] = true
+	}
+
+	return func(kh uint64) bool {
+		return sel[kh%uint64(buckets)]
+	}
+}
+
+// MapOf is a generic cache backend, for example *cache.ShardedMapOf or a replication log.
+type MapOf[V any] interface {
+	cache.WalkerOf[V]
+	Restore(r io.Reader) (int, error)
+	Len() int
+}
+
+// Of makes a transferable cache of a generic map.
+//
+// Imported entries are stored with Restore of the map, so that a replication log records them.
+// If the map is TombstonesOf, imported entries of recently deleted keys are skipped.
+func Of[V any](m MapOf[V]) Cache {
+	ts, _ := m.(tombstoner)
+
+	return shardedMap[V]{m: m, ts: ts}
+}
+
+type shardedMap[V any] struct {
+	m  MapOf[V]
+	ts tombstoner
+}
+
+func (s shardedMap[V]) Len() int {
+	return s.m.Len()
+}
+
+func (s shardedMap[V]) Walk(cb func(e cache.Entry) error) (int, error) {
+	return s.m.Walk(func(e cache.EntryOf[V]) error {
+		return cb(cache.TraitEntry{
+			K: e.Key(),
+			V: e.Value(),
+			E: e.ExpireAt().UnixNano(),
+		})
+	})
+}
+
+func (s shardedMap[V]) Dump(w io.Writer, f Filter) (int, error) {
+	var (
+		enc      = gob.NewEncoder(w)
+		minExp   = time.Now().Add(f.MinTTL).UnixNano()
+		match    = f.matcher()
+		selected []*cache.TraitEntryOf[V]
+		n        int
+	)
+
+	_, err := s.m.Walk(func(e cache.EntryOf[V]) error {
+		te, ok := e.(*cache.TraitEntryOf[V])
+		if !ok {
+			te = &cache.TraitEntryOf[V]{K: e.Key(), V: e.Value(), E: e.ExpireAt().UnixNano()}
+		}
+
+		if te.E != 0 && te.E < minExp {
+			return nil
+		}
+
+		if !match(te.K, te.E) {
+			return nil
+		}
+
+		// Without limit entries are streamed as is.
+		if f.Limit <= 0 {
+			n++
+
+			return enc.Encode(entryOf(te))
+		}
+
+		selected = append(selected, te)
+
+		return nil
+	})
+	if err != nil || f.Limit <= 0 {
+		return n, err
+	}
+
+	// Usage counter is a last serve timestamp or a number of serves, so in both cases larger is hotter.
+	usage := make(map[*cache.TraitEntryOf[V]]int64, len(selected))
+	for _, te := range selected {
+		usage[te] = atomic.LoadInt64(&te.C)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		ui, uj := usage[selected[i]], usage[selected[j]]
+		if ui != uj {
+			return ui > uj
+		}
+
+		return selected[i].E > selected[j].E
+	})
+
+	if len(selected) > f.Limit {
+		selected = selected[:f.Limit]
+	}
+
+	for _, te := range selected {
+		if err := enc.Encode(entryOf(te)); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// entryOf makes a copy of entry to avoid concurrent access to usage counter.
+func entryOf[V any](te *cache.TraitEntryOf[V]) cache.TraitEntryOf[V] {
+	return cache.TraitEntryOf[V]{K: te.K, V: te.V, E: te.E, C: atomic.LoadInt64(&te.C)}
+}
+
+func (s shardedMap[V]) Restore(r io.Reader) (int, error) {
+	if s.ts == nil {
+		return s.m.Restore(r)
+	}
+
+	return s.restore(r, func(e cache.TraitEntryOf[V]) bool {
+		return !s.ts.deleted(e.K, e.E)
+	})
+}
+
+func (s shardedMap[V]) Merge(r io.Reader) (int, error) {
+	local := make(map[string]int64, s.m.Len())
+
+	if _, err := s.m.Walk(func(e cache.EntryOf[V]) error {
+		local[string(e.Key())] = e.ExpireAt().UnixNano()
+
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return s.restore(r, func(e cache.TraitEntryOf[V]) bool {
+		if exp, ok := local[string(e.K)]; ok && !newer(e.E, exp) {
+			return false
+		}
+
+		return s.ts == nil || !s.ts.deleted(e.K, e.E)
+	})
+}
+
+// restore stores entries that pass keep, entries received before an error are stored too.
+func (s shardedMap[V]) restore(r io.Reader, keep func(e cache.TraitEntryOf[V]) bool) (int, error) {
+	var (
+		dec   = gob.NewDecoder(r)
+		fresh bytes.Buffer
+		enc   = gob.NewEncoder(&fresh)
+	)
+
+	for {
+		var e cache.TraitEntryOf[V]
+
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+
+			// Entries decoded before an error are kept, like with a plain cache backend.
+			n, rerr := s.m.Restore(&fresh)
+			if err == nil {
+				err = rerr
+			}
+
+			return n, err
+		}
+
+		if !keep(e) {
+			continue
+		}
+
+		if err := enc.Encode(e); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Digest hashes values instead of expiration times, so that instances with the same values agree
+// even if they have built them at different times.
+func (s shardedMap[V]) Digest(buckets int) ([]uint64, error) {
+	var (
+		digests = make([]uint64, buckets)
+		buf     bytes.Buffer
+		enc     = gob.NewEncoder(&buf)
+		h       = fnv.New64a()
+	)
+
+	// Type is sent with the first value, so encoder is primed to have only values in the buffer.
+	if err := enc.Encode(new(V)); err != nil {
+		return nil, err
+	}
+
+	_, err := s.m.Walk(func(e cache.EntryOf[V]) error {
+		buf.Reset()
+
+		if err := enc.Encode(e.Value()); err != nil {
+			return err
+		}
+
+		h.Reset()
+		_, _ = h.Write(buf.Bytes()) //nolint:errcheck // Hash never fails to write.
+
+		kh := keyHash(e.Key())
+		digests[kh%uint64(buckets)] ^= entryDigest(kh, h.Sum64())
+
+		return nil
+	})
+
+	return digests, err
+}
+
+func (s shardedMap[V]) Expirations(buckets int, selected []int) (map[uint64]int64, error) {
+	exp := make(map[uint64]int64)
+	inBucket := bucketMatcher(buckets, selected)
+
+	_, err := s.m.Walk(func(e cache.EntryOf[V]) error {
+		kh := keyHash(e.Key())
+		if inBucket(kh) {
+			exp[kh] = e.ExpireAt().UnixNano()
+		}
+
+		return nil
+	})
+
+	// Peer does not send entries of deleted keys that were written before the delete.
+	if s.ts != nil {
+		for kh, deadline := range s.ts.tombstones(inBucket) {
+			if _, ok := exp[kh]; !ok {
+				exp[kh] = deadline
+			}
+		}
+	}
+
+	return exp, err
+}
+
+// newer checks if expiration time is later than existing one,
+// zero expiration never expires, so it is the latest one.
+func newer(exp, existing int64) bool {
+	if existing == 0 {
+		return false
+	}
+
+	return exp == 0 || exp > existing
+}
+
+func keyHash(key []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(key) //nolint:errcheck // Hash never fails to write.
+
+	return h.Sum64()
+}
+
+// entryDigest mixes key and value hashes, digests of entries are combined with XOR
+// to make bucket digest independent of walk order.
+func entryDigest(kh, vh uint64) uint64 {
+	// SplitMix64 finalizer.
+	z := kh + vh*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+// CacheSummary describes contents of a cache.
+type CacheSummary struct {
+	Items            int       `json:"items"`
+	Fresh            int       `json:"fresh"`
+	FreshestExpireAt time.Time `json:"freshestExpireAt"`
+}
+
+func summarize(c Cache) (CacheSummary, error) {
+	s := CacheSummary{}
+	now := time.Now()
+
+	n, err := c.Walk(func(e cache.Entry) error {
+		exp := e.ExpireAt()
+
+		// Zero expiration timestamp stands for entry that never expires.
+		if exp.UnixNano() == 0 || exp.After(now) {
+			s.Fresh++
+		}
+
+		if exp.After(s.FreshestExpireAt) {
+			s.FreshestExpireAt = exp
+		}
+
+		return nil
+	})
+
+	s.Items = n
+
+	return s, err
+}
@@ -0,0 +1,209 @@
+package transfer
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted payload is a sequence of AES-GCM sealed chunks, so that every chunk
+// is verified before it is used and partial transfer keeps verified entries.
+//
+// Payload starts with a random nonce base, followed by frames of
+// 1 byte flag (last chunk or not), 4 bytes length and sealed chunk.
+// Chunk nonce is nonce base with chunk counter added to the last 8 bytes,
+// flag is authenticated as additional data to detect truncation.
+
+const (
+	chunkSize = 64 * 1024
+
+	flagMore = byte(0)
+	flagLast = byte(1)
+)
+
+var errTampered = errors.New("cache transfer payload is corrupted or tampered")
+
+func newAEAD(hexKey string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache transfer key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache transfer key: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(base []byte, counter uint64) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)+counter)
+
+	return nonce
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	base    []byte
+	buf     []byte
+	counter uint64
+}
+
+func newEncryptWriter(w io.Writer, aead cipher.AEAD) (*encryptWriter, error) {
+	base := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(base); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(base); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:    w,
+		aead: aead,
+		base: base,
+		buf:  make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := 0
+
+	for len(p) > 0 {
+		l := chunkSize - len(e.buf)
+		if l > len(p) {
+			l = len(p)
+		}
+
+		e.buf = append(e.buf, p[:l]...)
+		p = p[l:]
+		n += l
+
+		if len(e.buf) == chunkSize {
+			if err := e.flush(flagMore); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Flush seals buffered data into a chunk, so that it can be read without waiting for a full chunk.
+func (e *encryptWriter) Flush() error {
+	if len(e.buf) == 0 {
+		return nil
+	}
+
+	return e.flush(flagMore)
+}
+
+// Close writes the last chunk, it does not close underlying writer.
+func (e *encryptWriter) Close() error {
+	return e.flush(flagLast)
+}
+
+func (e *encryptWriter) flush(flag byte) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.base, e.counter), e.buf, []byte{flag})
+	e.counter++
+	e.buf = e.buf[:0]
+
+	header := make([]byte, 5)
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := e.w.Write(sealed)
+
+	return err
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	base    []byte
+	buf     []byte
+	counter uint64
+	last    bool
+}
+
+func newDecryptReader(r io.Reader, aead cipher.AEAD) (*decryptReader, error) {
+	base := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, base); err != nil {
+		return nil, fmt.Errorf("failed to read nonce: %w", err)
+	}
+
+	return &decryptReader{
+		r:    bufio.NewReader(r),
+		aead: aead,
+		base: base,
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.last {
+			return 0, io.EOF
+		}
+
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		// Stream has ended before the last chunk.
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	flag := header[0]
+	size := binary.BigEndian.Uint32(header[1:])
+
+	if (flag != flagMore && flag != flagLast) || size > chunkSize+uint32(d.aead.Overhead()) {
+		return errTampered
+	}
+
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return err
+	}
+
+	plain, err := d.aead.Open(sealed[:0], chunkNonce(d.base, d.counter), sealed, []byte{flag})
+	if err != nil {
+		return errTampered
+	}
+
+	d.counter++
+	d.buf = plain
+	d.last = flag == flagLast
+
+	return nil
+}
@@ -0,0 +1,2 @@
+// Package transfer provides cache transfer between application instances.
+package transfer
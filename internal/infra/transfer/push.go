@@ -0,0 +1,161 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Push sends registered caches to Config.SuccessorURL.
+//
+// It is intended to be called during graceful shutdown, so that successor instance
+// receives warm cache without pull configuration.
+func (t *Transfer) Push(ctx context.Context) error {
+	if t.Config.SuccessorURL == "" {
+		return nil
+	}
+
+	for _, name := range t.importNames() {
+		if err := t.pushCache(ctx, name, t.caches[name]); err != nil {
+			return fmt.Errorf("failed to push cache %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *Transfer) pushCache(ctx context.Context, name string, c Cache) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout())
+	defer cancel()
+
+	start := time.Now()
+
+	u, err := url.Parse(t.Config.SuccessorURL)
+	if err != nil {
+		return err
+	}
+
+	q := u.Query()
+	q.Set("name", name)
+	q.Set("typesHash", typesHash())
+	u.RawQuery = q.Encode()
+
+	pr, pw := io.Pipe()
+
+	// Closed reader stops the dump if request fails before the body is sent.
+	defer func() {
+		_ = pr.Close() //nolint:errcheck // Always nil.
+	}()
+
+	type dumped struct {
+		n   int
+		err error
+	}
+
+	done := make(chan dumped, 1)
+
+	go func() {
+		n, err := t.dump(pw, c, Filter{MinTTL: t.Config.MinTTL, Limit: t.Config.Limit})
+		_ = pw.CloseWithError(err) //nolint:errcheck // Always nil.
+
+		done <- dumped{n: n, err: err}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), pr)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	t.authorize(req, importScope(name))
+
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil {
+		return err
+	}
+
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) //nolint:errcheck // Best effort error details.
+
+		return fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, string(body))
+	}
+
+	d := <-done
+	if d.err != nil {
+		return d.err
+	}
+
+	t.logger().Important(ctx, "cache pushed",
+		"name", name, "url", t.Config.SuccessorURL, "processed", d.n, "elapsed", time.Since(start).String())
+
+	return nil
+}
+
+// Receive creates http handler to import cache entries pushed by predecessor instance.
+//
+// Received entries are merged into local cache, fresher local entries are kept.
+// Requests are rejected if Config.Secret is not set, because unauthenticated import would allow cache poisoning.
+func (t *Transfer) Receive() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query()
+		name := q.Get("name")
+
+		if t.Config.Secret == "" {
+			http.Error(rw, "cache import requires transfer secret", http.StatusForbidden)
+
+			return
+		}
+
+		if err := t.authenticate(r, importScope(name)); err != nil {
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+
+		c, ok := t.caches[name]
+		if !ok {
+			http.Error(rw, "cache not found for "+name, http.StatusNotFound)
+
+			return
+		}
+
+		if q.Get("typesHash") != typesHash() {
+			http.Error(rw, "typesHash mismatch, incompatible cache", http.StatusBadRequest)
+
+			return
+		}
+
+		start := time.Now()
+
+		body, err := t.payloadReader(r.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		n, err := c.Merge(body)
+		if err != nil {
+			t.logger().Warn(ctx, "failed to merge received cache", "error", err, "name", name)
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		t.logger().Important(ctx, "cache received",
+			"name", name, "merged", n, "elapsed", time.Since(start).String())
+
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(rw, `{"merged":%d}`+"\n", n) //nolint:errcheck // Nothing to do with failed response.
+	})
+}
+
+func importScope(name string) string {
+	return "import:" + name
+}
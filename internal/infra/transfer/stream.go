@@ -0,0 +1,53 @@
+package transfer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+var errNoSecret = errors.New("cache stream requires transfer secret")
+
+// streamScope is a subject of token of cache replication stream.
+func streamScope(name string) string {
+	return "replicate:" + name
+}
+
+// AuthorizeStream sets token of cache replication stream to request if secret is configured.
+func (t *Transfer) AuthorizeStream(req *http.Request, name string) {
+	t.authorize(req, streamScope(name))
+}
+
+// AuthenticateStream checks token of cache replication stream, unlike export it requires Config.Secret.
+func (t *Transfer) AuthenticateStream(r *http.Request, name string) error {
+	if t.Config.Secret == "" {
+		return errNoSecret
+	}
+
+	return t.authenticate(r, streamScope(name))
+}
+
+// EncryptStream wraps writer to encrypt stream if Config.Key is set,
+// flush seals buffered data, so that receiver can use it without waiting for more data.
+func (t *Transfer) EncryptStream(w io.Writer) (io.Writer, func() error, error) {
+	if t.Config.Key == "" {
+		return w, func() error { return nil }, nil
+	}
+
+	aead, err := newAEAD(t.Config.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ew, err := newEncryptWriter(w, aead)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ew, ew.Flush, nil
+}
+
+// DecryptStream verifies and decrypts stream if Config.Key is set.
+func (t *Transfer) DecryptStream(r io.Reader) (io.Reader, error) {
+	return t.payloadReader(r)
+}
@@ -0,0 +1,236 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxBuckets limits the size of digests.
+const maxBuckets = 1 << 16
+
+type digestResponse struct {
+	Digests []uint64 `json:"digests"`
+}
+
+type syncRequest struct {
+	Buckets  int              `json:"buckets"`
+	Selected []int            `json:"selected"`
+	Known    map[uint64]int64 `json:"known"`
+}
+
+// SyncRound describes results of anti-entropy round.
+type SyncRound struct {
+	URL           string
+	BytesSent     int64
+	BytesReceived int64
+	Buckets       int // Number of buckets with different digests.
+	Repaired      int // Number of missing or newer entries received from peer.
+}
+
+func (t *Transfer) exportDigest(rw http.ResponseWriter, r *http.Request, c Cache, buckets string) {
+	n, err := strconv.Atoi(buckets)
+	if err != nil || n <= 0 || n > maxBuckets {
+		http.Error(rw, "invalid digest buckets: "+buckets, http.StatusBadRequest)
+
+		return
+	}
+
+	d, err := c.Digest(n)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(digestResponse{Digests: d}); err != nil {
+		t.logger().Error(r.Context(), "failed to write cache digest", "error", err)
+	}
+}
+
+// RunSync performs anti-entropy rounds every Config.SyncInterval until context is done.
+//
+// Every round takes the next peer and pulls entries that are missing or newer.
+func (t *Transfer) RunSync(ctx context.Context, extraURLs ...string) {
+	if t.Config.SyncInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(t.Config.SyncInterval)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		urls, err := t.sourceURLs(ctx, extraURLs)
+		if err != nil {
+			t.logger().Warn(ctx, "failed to find cache sync peers", "error", err)
+
+			continue
+		}
+
+		if len(urls) == 0 {
+			continue
+		}
+
+		if _, err := t.Sync(ctx, urls[i%len(urls)]); err != nil {
+			t.logger().Warn(ctx, "cache sync failed", "error", err)
+		}
+	}
+}
+
+// Sync performs anti-entropy round with a peer.
+//
+// Digests of key buckets are compared with peer, and for buckets that differ,
+// peer sends entries that are missing locally or expire later than local ones.
+func (t *Transfer) Sync(ctx context.Context, exportURL string) (SyncRound, error) {
+	start := time.Now()
+	round := SyncRound{URL: exportURL}
+
+	for _, name := range t.importNames() {
+		if err := t.syncCache(ctx, exportURL, name, t.caches[name], &round); err != nil {
+			t.stats().Add(ctx, "cache_sync_rounds", 1, "result", "failed")
+
+			return round, fmt.Errorf("failed to sync cache %s: %w", name, err)
+		}
+	}
+
+	t.stats().Add(ctx, "cache_sync_rounds", 1, "result", "ok")
+	t.logger().Info(ctx, "cache sync round finished",
+		"url", exportURL, "buckets", round.Buckets, "repaired", round.Repaired,
+		"bytesSent", round.BytesSent, "bytesReceived", round.BytesReceived,
+		"elapsed", time.Since(start).String())
+
+	return round, nil
+}
+
+func (t *Transfer) syncCache(ctx context.Context, exportURL, name string, c Cache, round *SyncRound) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout())
+	defer cancel()
+
+	buckets := t.Config.SyncBuckets
+	if buckets <= 0 {
+		buckets = 1024
+	}
+
+	params := url.Values{
+		"name":      []string{name},
+		"typesHash": []string{typesHash()},
+	}
+
+	// Comparing digests.
+	params.Set("digest", strconv.Itoa(buckets))
+
+	resp, err := t.get(ctx, exportURL, name, params)
+	if err != nil {
+		return err
+	}
+
+	var (
+		remote digestResponse
+		cr     = &countingReader{r: resp.Body}
+	)
+
+	err = json.NewDecoder(cr).Decode(&remote)
+
+	closeBody(resp)
+	t.countBytes(ctx, name, round, 0, cr.n)
+
+	if err != nil {
+		return fmt.Errorf("failed to decode digest: %w", err)
+	}
+
+	local, err := c.Digest(buckets)
+	if err != nil {
+		return err
+	}
+
+	if len(remote.Digests) != len(local) {
+		return fmt.Errorf("unexpected number of digests: %d", len(remote.Digests))
+	}
+
+	req := syncRequest{Buckets: buckets}
+
+	for i, d := range local {
+		if d != remote.Digests[i] {
+			req.Selected = append(req.Selected, i)
+		}
+	}
+
+	if len(req.Selected) == 0 {
+		return nil
+	}
+
+	round.Buckets += len(req.Selected)
+
+	// Pulling entries of different buckets.
+	if req.Known, err = c.Expirations(buckets, req.Selected); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	params.Del("digest")
+
+	resp, err = t.request(ctx, http.MethodPost, exportURL, name, params, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer closeBody(resp)
+
+	cr = &countingReader{r: resp.Body}
+
+	payload, err := t.payloadReader(cr)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.Merge(payload)
+
+	t.countBytes(ctx, name, round, int64(len(body)), cr.n)
+
+	if err != nil {
+		return fmt.Errorf("failed to merge entries: %w", err)
+	}
+
+	round.Repaired += n
+	t.stats().Add(ctx, "cache_sync_repaired", float64(n), "name", name)
+
+	return nil
+}
+
+func (t *Transfer) countBytes(ctx context.Context, name string, round *SyncRound, sent, received int64) {
+	round.BytesSent += sent
+	round.BytesReceived += received
+
+	t.stats().Add(ctx, "cache_sync_bytes", float64(sent), "name", name, "direction", "sent")
+	t.stats().Add(ctx, "cache_sync_bytes", float64(received), "name", name, "direction", "received")
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
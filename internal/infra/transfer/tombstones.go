@@ -0,0 +1,110 @@
+package transfer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bool64/cache"
+)
+
+// BackendOf is a transferable cache backend.
+type BackendOf[V any] interface {
+	MapOf[V]
+	cache.ReadWriterOf[V]
+	cache.Deleter
+}
+
+// TombstonesOf is a cache backend that records deleted keys,
+// so that anti-entropy sync and cache import do not bring deleted entries back from peers.
+//
+// Tombstone lives for cache TTL with a margin for expiration jitter: peer entries that were written
+// before the delete expire by then, and entries that expire later were written after the delete, so they are accepted.
+// A local write after the delete removes the tombstone. Peer entries that were written within the margin
+// after the delete (up to 15% of TTL) can not be told from older ones, they are rejected until tombstone expires
+// and are restored by a later sync.
+type TombstonesOf[V any] struct {
+	BackendOf[V]
+
+	ttl     time.Duration
+	mu      sync.Mutex
+	keys    map[uint64]int64 // Tombstone deadlines in Unix nanoseconds by key hash.
+	sweepAt int64
+}
+
+// NewTombstonesOf creates a backend that records deletes as tombstones for cache TTL.
+func NewTombstonesOf[V any](b BackendOf[V], ttl time.Duration) *TombstonesOf[V] {
+	return &TombstonesOf[V]{
+		BackendOf: b,
+		ttl:       ttl + ttl/10, // Cache randomizes TTL by ±5% by default.
+		keys:      make(map[uint64]int64),
+	}
+}
+
+// Delete removes value and records a tombstone.
+func (t *TombstonesOf[V]) Delete(ctx context.Context, key []byte) error {
+	now := time.Now().UnixNano()
+
+	t.mu.Lock()
+	t.keys[keyHash(key)] = now + int64(t.ttl)
+
+	// Expired tombstones are removed once per TTL.
+	if now >= t.sweepAt {
+		for kh, deadline := range t.keys {
+			if deadline <= now {
+				delete(t.keys, kh)
+			}
+		}
+
+		t.sweepAt = now + int64(t.ttl)
+	}
+	t.mu.Unlock()
+
+	return t.BackendOf.Delete(ctx, key)
+}
+
+// Write stores value and removes a tombstone, because the value is newer than the delete.
+func (t *TombstonesOf[V]) Write(ctx context.Context, key []byte, value V) error {
+	t.mu.Lock()
+	delete(t.keys, keyHash(key))
+	t.mu.Unlock()
+
+	return t.BackendOf.Write(ctx, key, value)
+}
+
+// tombstones returns deadlines of live tombstones by key hash for keys that match.
+func (t *TombstonesOf[V]) tombstones(match func(kh uint64) bool) map[uint64]int64 {
+	now := time.Now().UnixNano()
+	res := make(map[uint64]int64)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for kh, deadline := range t.keys {
+		if deadline > now && match(kh) {
+			res[kh] = deadline
+		}
+	}
+
+	return res
+}
+
+// deleted checks if an entry with expiration time was written before a live tombstone of its key,
+// entries that never expire are rejected while tombstone lives.
+func (t *TombstonesOf[V]) deleted(key []byte, exp int64) bool {
+	t.mu.Lock()
+	deadline, ok := t.keys[keyHash(key)]
+	t.mu.Unlock()
+
+	if !ok || deadline <= time.Now().UnixNano() {
+		return false
+	}
+
+	return exp == 0 || exp <= deadline
+}
+
+// tombstoner is implemented by TombstonesOf.
+type tombstoner interface {
+	tombstones(match func(kh uint64) bool) map[uint64]int64
+	deleted(key []byte, exp int64) bool
+}
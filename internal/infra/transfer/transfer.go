@@ -0,0 +1,645 @@
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/bool64/ctxd"
+	"github.com/bool64/stats"
+)
+
+// Config controls cache transfer on application start.
+type Config struct {
+	// URLs is a list of export URLs of peer instances to fetch cache from.
+	URLs []string `envconfig:"URLS"`
+
+	// DNSName is a host:port, host is resolved to addresses of peer instances.
+	DNSName string `split_words:"true"`
+
+	// Path is an export path of peer instances discovered with DNSName.
+	Path string `default:"/debug/transfer-cache"`
+
+	// Timeout limits time to probe or import from a single source.
+	Timeout time.Duration `default:"10s"`
+
+	// Names limits imported caches, all registered caches are imported by default.
+	Names []string `envconfig:"NAMES"`
+
+	// MinTTL skips entries that expire sooner.
+	MinTTL time.Duration `envconfig:"MIN_TTL"`
+
+	// Limit is a maximum number of entries to import per cache, hottest entries come first.
+	Limit int
+
+	// Budget limits total time of import, entries that were received within budget are kept.
+	Budget time.Duration
+
+	// Secret is a shared secret to sign and verify time-limited transfer tokens.
+	// If empty, export is not authenticated.
+	Secret string
+
+	// TokenTTL is a lifetime of transfer token.
+	TokenTTL time.Duration `split_words:"true" default:"1m"`
+
+	// Key is a hex-encoded AES key (16, 24 or 32 bytes) to encrypt exported entries.
+	// If empty, payload is not encrypted.
+	Key string
+
+	// SuccessorURL is an import URL of an instance to push cache to during shutdown.
+	SuccessorURL string `envconfig:"SUCCESSOR_URL"`
+
+	// SyncInterval is an interval of anti-entropy rounds with peers, zero disables periodic sync.
+	SyncInterval time.Duration `split_words:"true"`
+
+	// SyncBuckets is a number of key buckets to compare digests.
+	SyncBuckets int `split_words:"true" default:"1024"`
+}
+
+// Transfer exports and imports cache entries between application instances.
+//
+// Import probes available sources and fetches caches from the best one,
+// falling back to the next source on error or timeout.
+type Transfer struct {
+	Config    Config
+	Logger    ctxd.Logger
+	Transport http.RoundTripper
+	Resolver  interface {
+		LookupHost(ctx context.Context, host string) ([]string, error)
+	}
+	Stats stats.Tracker
+
+	caches map[string]Cache
+}
+
+// AddCache registers cache for transfer.
+func (t *Transfer) AddCache(name string, c Cache) {
+	if t.caches == nil {
+		t.caches = make(map[string]Cache)
+	}
+
+	t.caches[name] = c
+}
+
+// CachesCount returns how many caches were added.
+func (t *Transfer) CachesCount() int {
+	return len(t.caches)
+}
+
+// Summary describes caches available at a transfer source.
+type Summary struct {
+	TypesHash string                  `json:"typesHash"`
+	Caches    map[string]CacheSummary `json:"caches"`
+}
+
+// Export creates http handler to export cache entries in encoding/gob format.
+//
+// Request without name query parameter receives JSON Summary of available caches.
+// Optional minTTL (duration) and limit (integer) query parameters define Filter of exported entries.
+// Request with digest (number of buckets) query parameter receives JSON bucket digests for anti-entropy,
+// and POST request with JSON body selects entries of buckets that are missing or newer than known to requester.
+//
+// If Config.Secret is set, request must have a valid token.
+// If Config.Key is set, exported entries are encrypted.
+func (t *Transfer) Export() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query()
+		name := q.Get("name")
+
+		if err := t.authenticate(r, scope(name)); err != nil {
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+
+		if name == "" {
+			t.exportSummary(rw, r)
+
+			return
+		}
+
+		c, ok := t.caches[name]
+		if !ok {
+			http.Error(rw, "cache not found for "+name, http.StatusNotFound)
+
+			return
+		}
+
+		if q.Get("typesHash") != typesHash() {
+			http.Error(rw, "typesHash mismatch, incompatible cache", http.StatusBadRequest)
+
+			return
+		}
+
+		if v := q.Get("digest"); v != "" {
+			t.exportDigest(rw, r, c, v)
+
+			return
+		}
+
+		f, err := filterFromRequest(r)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		if t.Config.Key != "" {
+			if _, err := newAEAD(t.Config.Key); err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+		}
+
+		start := time.Now()
+
+		rw.Header().Set("Content-Type", "application/octet-stream")
+
+		n, err := t.dump(rw, c, f)
+		if err != nil {
+			t.logger().Error(ctx, "failed to dump cache",
+				"error", err, "name", name, "processed", n, "elapsed", time.Since(start).String())
+
+			return
+		}
+
+		t.logger().Important(ctx, "cache dump finished",
+			"name", name, "processed", n, "elapsed", time.Since(start).String())
+	})
+}
+
+// dump writes cache entries, encrypted if Config.Key is set.
+func (t *Transfer) dump(w io.Writer, c Cache, f Filter) (int, error) {
+	if t.Config.Key == "" {
+		return c.Dump(w, f)
+	}
+
+	aead, err := newAEAD(t.Config.Key)
+	if err != nil {
+		return 0, err
+	}
+
+	ew, err := newEncryptWriter(w, aead)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := c.Dump(ew, f)
+	if err != nil {
+		return n, err
+	}
+
+	return n, ew.Close()
+}
+
+// payloadReader verifies and decrypts cache entries if Config.Key is set.
+func (t *Transfer) payloadReader(r io.Reader) (io.Reader, error) {
+	if t.Config.Key == "" {
+		return r, nil
+	}
+
+	aead, err := newAEAD(t.Config.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecryptReader(r, aead)
+}
+
+// scope is a subject of transfer token.
+func scope(name string) string {
+	if name == "" {
+		return "summary"
+	}
+
+	return "cache:" + name
+}
+
+func filterFromRequest(r *http.Request) (Filter, error) {
+	var (
+		f   Filter
+		err error
+		q   = r.URL.Query()
+	)
+
+	if r.Method == http.MethodPost {
+		var req syncRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return f, fmt.Errorf("failed to decode sync request: %w", err)
+		}
+
+		if req.Buckets <= 0 || req.Buckets > maxBuckets {
+			return f, fmt.Errorf("invalid number of buckets: %d", req.Buckets)
+		}
+
+		f.Buckets = req.Buckets
+		f.Selected = req.Selected
+		f.Known = req.Known
+	}
+
+	if v := q.Get("minTTL"); v != "" {
+		if f.MinTTL, err = time.ParseDuration(v); err != nil {
+			return f, fmt.Errorf("invalid minTTL: %w", err)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+
+	return f, nil
+}
+
+func (t *Transfer) exportSummary(rw http.ResponseWriter, r *http.Request) {
+	s := Summary{
+		TypesHash: typesHash(),
+		Caches:    make(map[string]CacheSummary, len(t.caches)),
+	}
+
+	for name, c := range t.caches {
+		cs, err := summarize(c)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		s.Caches[name] = cs
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(s); err != nil {
+		t.logger().Error(r.Context(), "failed to write cache summary", "error", err)
+	}
+}
+
+// Import fetches registered caches from the best available source.
+//
+// Sources are ranked by number of fresh entries and expiration time of the freshest entry.
+// Unreachable and incompatible sources are skipped.
+// If import from a source fails, next source is used for the remaining caches.
+// If Config.Budget is exhausted, import stops keeping received entries.
+//
+// Export URLs of sources that were used are returned.
+func (t *Transfer) Import(ctx context.Context, extraURLs ...string) ([]string, error) {
+	names := t.importNames()
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	if t.Config.Budget > 0 {
+		var cancel func()
+
+		ctx, cancel = context.WithTimeout(ctx, t.Config.Budget)
+		defer cancel()
+	}
+
+	urls, err := t.sourceURLs(ctx, extraURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	sources := t.rank(ctx, urls)
+	if len(sources) == 0 {
+		return nil, errors.New("no cache transfer source available")
+	}
+
+	var (
+		used   []string
+		failed = make(map[string]bool)
+	)
+
+	use := func(u string) {
+		if !slices.Contains(used, u) {
+			used = append(used, u)
+		}
+	}
+
+	for _, name := range names {
+		imported := false
+
+		for _, src := range sources {
+			if failed[src.url] {
+				continue
+			}
+
+			if _, ok := src.summary.Caches[name]; !ok {
+				continue
+			}
+
+			if err := t.importCache(ctx, src.url, name, t.caches[name]); err != nil {
+				if t.Config.Budget > 0 && ctx.Err() != nil {
+					t.logger().Warn(ctx, "cache transfer budget exhausted",
+						"error", err, "name", name, "url", src.url, "budget", t.Config.Budget.String())
+
+					use(src.url)
+
+					return used, nil
+				}
+
+				t.logger().Warn(ctx, "failed to import cache, trying next source",
+					"error", err, "name", name, "url", src.url)
+
+				failed[src.url] = true
+
+				continue
+			}
+
+			imported = true
+
+			use(src.url)
+
+			break
+		}
+
+		if !imported {
+			return used, fmt.Errorf("failed to import cache %s: all sources failed", name)
+		}
+	}
+
+	return used, nil
+}
+
+// importNames returns sorted names of registered caches that are allowed by Config.Names.
+func (t *Transfer) importNames() []string {
+	names := make([]string, 0, len(t.caches))
+
+	for name := range t.caches {
+		if len(t.Config.Names) > 0 && !slices.Contains(t.Config.Names, name) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+type source struct {
+	url      string
+	summary  Summary
+	fresh    int
+	freshest time.Time
+}
+
+// rank probes sources and sorts available ones from best to worst.
+func (t *Transfer) rank(ctx context.Context, urls []string) []source {
+	sources := make([]source, 0, len(urls))
+
+	for _, u := range urls {
+		s, err := t.probe(ctx, u)
+		if err != nil {
+			t.logger().Warn(ctx, "cache transfer source unavailable", "error", err, "url", u)
+
+			continue
+		}
+
+		if s.TypesHash != typesHash() {
+			t.logger().Warn(ctx, "cache transfer source incompatible", "url", u)
+
+			continue
+		}
+
+		src := source{url: u, summary: s}
+
+		for _, name := range t.importNames() {
+			cs := s.Caches[name]
+
+			src.fresh += cs.Fresh
+
+			if cs.FreshestExpireAt.After(src.freshest) {
+				src.freshest = cs.FreshestExpireAt
+			}
+		}
+
+		sources = append(sources, src)
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].fresh != sources[j].fresh {
+			return sources[i].fresh > sources[j].fresh
+		}
+
+		return sources[i].freshest.After(sources[j].freshest)
+	})
+
+	return sources
+}
+
+func (t *Transfer) probe(ctx context.Context, exportURL string) (Summary, error) {
+	s := Summary{}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout())
+	defer cancel()
+
+	resp, err := t.get(ctx, exportURL, "", nil)
+	if err != nil {
+		return s, err
+	}
+
+	defer closeBody(resp)
+
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return s, fmt.Errorf("failed to decode cache summary: %w", err)
+	}
+
+	return s, nil
+}
+
+func (t *Transfer) importCache(ctx context.Context, exportURL string, name string, c Cache) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout())
+	defer cancel()
+
+	start := time.Now()
+
+	params := url.Values{
+		"name":      []string{name},
+		"typesHash": []string{typesHash()},
+	}
+
+	if t.Config.MinTTL > 0 {
+		params.Set("minTTL", t.Config.MinTTL.String())
+	}
+
+	if t.Config.Limit > 0 {
+		params.Set("limit", strconv.Itoa(t.Config.Limit))
+	}
+
+	resp, err := t.get(ctx, exportURL, name, params)
+	if err != nil {
+		return err
+	}
+
+	defer closeBody(resp)
+
+	body, err := t.payloadReader(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.Restore(body)
+	if err != nil {
+		return fmt.Errorf("failed to restore cache dump after %d entries: %w", n, err)
+	}
+
+	t.logger().Important(ctx, "cache restored",
+		"name", name, "url", exportURL, "processed", n, "elapsed", time.Since(start).String())
+
+	return nil
+}
+
+func (t *Transfer) get(ctx context.Context, exportURL string, name string, params url.Values) (*http.Response, error) {
+	return t.request(ctx, http.MethodGet, exportURL, name, params, nil)
+}
+
+func (t *Transfer) request(
+	ctx context.Context, method, exportURL string, name string, params url.Values, body io.Reader,
+) (*http.Response, error) {
+	u, err := url.Parse(exportURL)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	t.authorize(req, scope(name))
+
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) //nolint:errcheck // Best effort error details.
+		closeBody(resp)
+
+		return nil, fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// sourceURLs collects configured and DNS-discovered export URLs.
+func (t *Transfer) sourceURLs(ctx context.Context, extraURLs []string) ([]string, error) {
+	urls := make([]string, 0, len(t.Config.URLs)+len(extraURLs))
+	seen := make(map[string]bool)
+
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+
+			urls = append(urls, u)
+		}
+	}
+
+	for _, u := range extraURLs {
+		add(u)
+	}
+
+	for _, u := range t.Config.URLs {
+		add(u)
+	}
+
+	if t.Config.DNSName == "" {
+		return urls, nil
+	}
+
+	host, port, err := net.SplitHostPort(t.Config.DNSName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache transfer DNS name: %w", err)
+	}
+
+	var r interface {
+		LookupHost(ctx context.Context, host string) ([]string, error)
+	} = net.DefaultResolver
+
+	if t.Resolver != nil {
+		r = t.Resolver
+	}
+
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cache transfer peers: %w", err)
+	}
+
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		add("http://" + net.JoinHostPort(addr, port) + t.Config.Path)
+	}
+
+	return urls, nil
+}
+
+func (t *Transfer) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Transport
+}
+
+func (t *Transfer) timeout() time.Duration {
+	if t.Config.Timeout == 0 {
+		return 10 * time.Second
+	}
+
+	return t.Config.Timeout
+}
+
+func (t *Transfer) logger() ctxd.Logger {
+	if t.Logger == nil {
+		return ctxd.NoOpLogger{}
+	}
+
+	return t.Logger
+}
+
+func (t *Transfer) stats() stats.Tracker {
+	if t.Stats == nil {
+		return stats.NoOp{}
+	}
+
+	return t.Stats
+}
+
+func typesHash() string {
+	return strconv.FormatUint(cache.GobTypesHash(), 10)
+}
+
+func closeBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body) //nolint:errcheck // Draining body to reuse connection.
+	_ = resp.Body.Close()                 //nolint:errcheck // Nothing to do with the error.
+}
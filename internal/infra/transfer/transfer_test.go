@@ -0,0 +1,448 @@
+package transfer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bool64/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/cache-story/internal/infra/transfer"
+)
+
+type instance struct {
+	t     *transfer.Transfer
+	cache *cache.ShardedMapOf[string]
+	srv   *httptest.Server
+}
+
+func newInstance(t *testing.T, items int, ttl time.Duration, options ...func(cfg *cache.Config)) *instance {
+	t.Helper()
+
+	i := &instance{}
+	i.cache = cache.NewShardedMapOf[string](append(options, func(cfg *cache.Config) {
+		cfg.TimeToLive = ttl
+	})...)
+	i.t = &transfer.Transfer{Config: transfer.Config{Timeout: time.Second}}
+	i.t.AddCache("greetings", transfer.Of(i.cache))
+
+	for j := 0; j < items; j++ {
+		require.NoError(t, i.cache.Write(context.Background(), []byte("key"+strconv.Itoa(j)), "val"+strconv.Itoa(j)))
+	}
+
+	i.srv = httptest.NewServer(i.t.Export())
+	t.Cleanup(i.srv.Close)
+
+	return i
+}
+
+// withTombstones replaces cache of instance transfer with a backend that records deletes, like in production.
+func withTombstones(i *instance) {
+	cfg := i.t.Config
+	i.t = &transfer.Transfer{Config: cfg}
+	i.t.AddCache("greetings", transfer.Of[string](transfer.NewTombstonesOf[string](i.cache, time.Minute)))
+}
+
+func TestTransfer_Import(t *testing.T) {
+	small := newInstance(t, 10, time.Minute)
+	large := newInstance(t, 30, time.Minute)
+	expired := newInstance(t, 50, -time.Minute)
+	down := newInstance(t, 100, time.Minute)
+	down.srv.Close()
+
+	dst := newInstance(t, 0, time.Minute)
+
+	used, err := dst.t.Import(context.Background(), down.srv.URL, small.srv.URL, expired.srv.URL, large.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{large.srv.URL}, used)
+	assert.Equal(t, 30, dst.cache.Len())
+
+	v, err := dst.cache.Read(context.Background(), []byte("key29"))
+	require.NoError(t, err)
+	assert.Equal(t, "val29", v)
+}
+
+func TestTransfer_Import_fallback(t *testing.T) {
+	small := newInstance(t, 10, time.Minute)
+	large := newInstance(t, 30, time.Minute)
+
+	// Broken instance is available for probing, but fails to export.
+	broken := newInstance(t, 100, time.Minute)
+	broken.srv.Config.Handler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != "" {
+			http.Error(rw, "oops", http.StatusInternalServerError)
+
+			return
+		}
+
+		broken.t.Export().ServeHTTP(rw, r)
+	})
+
+	dst := newInstance(t, 0, time.Minute)
+	dst.t.Config.URLs = []string{small.srv.URL, broken.srv.URL, large.srv.URL}
+
+	used, err := dst.t.Import(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{large.srv.URL}, used)
+	assert.Equal(t, 30, dst.cache.Len())
+}
+
+func TestTransfer_Import_allDown(t *testing.T) {
+	down := newInstance(t, 10, time.Minute)
+	down.srv.Close()
+
+	dst := newInstance(t, 0, time.Minute)
+
+	_, err := dst.t.Import(context.Background(), down.srv.URL)
+	require.Error(t, err)
+	assert.Equal(t, 0, dst.cache.Len())
+}
+
+func TestTransfer_Import_filtered(t *testing.T) {
+	ctx := context.Background()
+
+	src := newInstance(t, 10, time.Minute, func(cfg *cache.Config) {
+		cfg.EvictionStrategy = cache.EvictLeastFrequentlyUsed
+	})
+
+	// Short-lived entries are hot, but are skipped by min TTL.
+	for j := 0; j < 5; j++ {
+		require.NoError(t, src.cache.Write(cache.WithTTL(ctx, 5*time.Second, false), []byte("short"+strconv.Itoa(j)), "short"))
+	}
+
+	for key, reads := range map[string]int{"key7": 3, "key2": 2, "key5": 1, "short1": 10} {
+		for j := 0; j < reads; j++ {
+			_, err := src.cache.Read(ctx, []byte(key))
+			require.NoError(t, err)
+		}
+	}
+
+	dst := newInstance(t, 0, time.Minute)
+	dst.t.Config.MinTTL = 30 * time.Second
+	dst.t.Config.Limit = 2
+
+	used, err := dst.t.Import(ctx, src.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{src.srv.URL}, used)
+	assert.Equal(t, 2, dst.cache.Len())
+
+	for _, key := range []string{"key7", "key2"} {
+		_, err := dst.cache.Read(ctx, []byte(key))
+		require.NoError(t, err, key)
+	}
+}
+
+func TestTransfer_Import_names(t *testing.T) {
+	src := newInstance(t, 10, time.Minute)
+
+	dst := newInstance(t, 0, time.Minute)
+	dst.t.Config.Names = []string{"other"}
+
+	used, err := dst.t.Import(context.Background(), src.srv.URL)
+	require.NoError(t, err)
+	assert.Empty(t, used)
+	assert.Equal(t, 0, dst.cache.Len())
+}
+
+func TestTransfer_Import_secured(t *testing.T) {
+	ctx := context.Background()
+	secured := func(i *instance) {
+		i.t.Config.Secret = "s3cr3t"
+		i.t.Config.Key = "000102030405060708090a0b0c0d0e0f000102030405060708090a0b0c0d0e0f"
+	}
+
+	// Enough entries to span multiple encrypted chunks.
+	src := newInstance(t, 5000, time.Minute)
+	secured(src)
+
+	resp, err := http.Get(src.srv.URL + "?name=greetings&typesHash=0")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	dst := newInstance(t, 0, time.Minute)
+	secured(dst)
+
+	used, err := dst.t.Import(ctx, src.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{src.srv.URL}, used)
+	assert.Equal(t, 5000, dst.cache.Len())
+
+	// Wrong secret.
+	dst = newInstance(t, 0, time.Minute)
+	secured(dst)
+	dst.t.Config.Secret = "wrong"
+
+	_, err = dst.t.Import(ctx, src.srv.URL)
+	require.Error(t, err)
+	assert.Equal(t, 0, dst.cache.Len())
+
+	// Tampered payload.
+	tampered := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		src.t.Export().ServeHTTP(rec, r)
+
+		body := rec.Body.Bytes()
+		if r.URL.Query().Get("name") != "" {
+			body[len(body)-1] ^= 0xFF
+		}
+
+		rw.WriteHeader(rec.Code)
+		_, _ = rw.Write(body)
+	}))
+	defer tampered.Close()
+
+	// Entries of verified chunks are kept, the last chunk is rejected.
+	for _, tombstones := range []bool{false, true} {
+		dst = newInstance(t, 0, time.Minute)
+		if tombstones {
+			withTombstones(dst)
+		}
+
+		secured(dst)
+
+		_, err = dst.t.Import(ctx, tampered.URL)
+		require.Error(t, err)
+		assert.Less(t, dst.cache.Len(), 5000)
+		assert.Greater(t, dst.cache.Len(), 0)
+	}
+}
+
+func TestTransfer_Import_budget(t *testing.T) {
+	src := newInstance(t, 5000, time.Minute)
+
+	// Source sends a part of entries and stalls.
+	slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		src.t.Export().ServeHTTP(rec, r)
+
+		if r.URL.Query().Get("name") == "" {
+			rw.WriteHeader(rec.Code)
+			_, _ = rw.Write(rec.Body.Bytes())
+
+			return
+		}
+
+		body := rec.Body.Bytes()
+
+		rw.WriteHeader(rec.Code)
+		_, _ = rw.Write(body[:len(body)/2])
+		rw.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+
+	for _, tombstones := range []bool{false, true} {
+		dst := newInstance(t, 0, time.Minute)
+		if tombstones {
+			withTombstones(dst)
+		}
+
+		dst.t.Config.Budget = 200 * time.Millisecond
+
+		// Entries received within budget are kept.
+		used, err := dst.t.Import(context.Background(), slow.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{slow.URL}, used)
+		assert.Greater(t, dst.cache.Len(), 1000)
+		assert.Less(t, dst.cache.Len(), 5000)
+	}
+}
+
+func TestTransfer_Push(t *testing.T) {
+	ctx := context.Background()
+
+	src := newInstance(t, 10, time.Minute)
+	src.t.Config.Secret = "s3cr3t"
+	src.t.Config.Key = "000102030405060708090a0b0c0d0e0f"
+
+	dst := newInstance(t, 0, time.Minute)
+	dst.t.Config = src.t.Config
+
+	// Successor has already rebuilt some entries, they are fresher than pushed.
+	require.NoError(t, dst.cache.Write(cache.WithTTL(ctx, 2*time.Minute, false), []byte("key1"), "fresh1"))
+	require.NoError(t, dst.cache.Write(cache.WithTTL(ctx, 2*time.Minute, false), []byte("key2"), "fresh2"))
+
+	// Stale local entry is replaced.
+	require.NoError(t, dst.cache.Write(cache.WithTTL(ctx, 10*time.Second, false), []byte("key3"), "stale3"))
+
+	recv := httptest.NewServer(dst.t.Receive())
+	defer recv.Close()
+
+	resp, err := http.Post(recv.URL+"?name=greetings", "application/octet-stream", nil)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Import is not allowed without secret.
+	unsecured := newInstance(t, 0, time.Minute)
+	unsecuredRecv := httptest.NewServer(unsecured.t.Receive())
+	defer unsecuredRecv.Close()
+
+	resp, err = http.Post(unsecuredRecv.URL+"?name=greetings", "application/octet-stream", nil)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	src.t.Config.SuccessorURL = recv.URL
+	require.NoError(t, src.t.Push(ctx))
+
+	assert.Equal(t, 10, dst.cache.Len())
+
+	for key, val := range map[string]string{"key0": "val0", "key1": "fresh1", "key2": "fresh2", "key3": "val3", "key9": "val9"} {
+		v, err := dst.cache.Read(ctx, []byte(key))
+		require.NoError(t, err, key)
+		assert.Equal(t, val, v, key)
+	}
+}
+
+func TestTransfer_Push_rejected(t *testing.T) {
+	src := newInstance(t, 5000, time.Minute)
+
+	// Successor rejects push without reading the body.
+	rejecting := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "busy", http.StatusServiceUnavailable)
+	}))
+	defer rejecting.Close()
+
+	src.t.Config.SuccessorURL = rejecting.URL
+
+	err := src.t.Push(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected response status 503")
+}
+
+func TestTransfer_Sync(t *testing.T) {
+	ctx := context.Background()
+	peer := newInstance(t, 0, time.Minute)
+	local := newInstance(t, 0, time.Minute)
+
+	for j := 0; j < 100; j++ {
+		k := []byte("key" + strconv.Itoa(j))
+
+		switch {
+		case j < 20: // Missing locally.
+			require.NoError(t, peer.cache.Write(ctx, k, "peer"))
+		case j < 30: // Newer on peer.
+			require.NoError(t, peer.cache.Write(cache.WithTTL(ctx, 2*time.Minute, false), k, "peer"))
+			require.NoError(t, local.cache.Write(cache.WithTTL(ctx, time.Minute, false), k, "local"))
+		case j < 40: // Newer locally.
+			require.NoError(t, peer.cache.Write(cache.WithTTL(ctx, time.Minute, false), k, "peer"))
+			require.NoError(t, local.cache.Write(cache.WithTTL(ctx, 2*time.Minute, false), k, "local"))
+		default: // Missing on peer.
+			require.NoError(t, local.cache.Write(ctx, k, "local"))
+		}
+	}
+
+	local.t.Config.SyncBuckets = 16
+
+	round, err := local.t.Sync(ctx, peer.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 30, round.Repaired)
+	assert.Positive(t, round.BytesSent)
+	assert.Positive(t, round.BytesReceived)
+	assert.Equal(t, 100, local.cache.Len())
+
+	for key, val := range map[string]string{"key0": "peer", "key25": "peer", "key35": "local", "key99": "local"} {
+		v, err := local.cache.Read(ctx, []byte(key))
+		require.NoError(t, err, key)
+		assert.Equal(t, val, v, key)
+	}
+
+	// Peer converges with local.
+	peer.t.Config.SyncBuckets = 16
+
+	round, err = peer.t.Sync(ctx, local.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 70, round.Repaired)
+	assert.Equal(t, 100, peer.cache.Len())
+
+	// Caches are in sync, only digests are exchanged.
+	round, err = local.t.Sync(ctx, peer.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 0, round.Repaired)
+	assert.Equal(t, 0, round.Buckets)
+	assert.Equal(t, int64(0), round.BytesSent)
+}
+
+func TestTransfer_Sync_sameValues(t *testing.T) {
+	ctx := context.Background()
+	peer := newInstance(t, 0, time.Minute)
+	local := newInstance(t, 0, time.Minute)
+
+	// Same values are built at different times.
+	for j := 0; j < 10; j++ {
+		require.NoError(t, peer.cache.Write(ctx, []byte("key"+strconv.Itoa(j)), "val"+strconv.Itoa(j)))
+	}
+
+	time.Sleep(time.Millisecond)
+
+	for j := 0; j < 10; j++ {
+		require.NoError(t, local.cache.Write(ctx, []byte("key"+strconv.Itoa(j)), "val"+strconv.Itoa(j)))
+	}
+
+	local.t.Config.SyncBuckets = 16
+
+	round, err := local.t.Sync(ctx, peer.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 0, round.Buckets)
+	assert.Equal(t, 0, round.Repaired)
+}
+
+func TestTransfer_Sync_tombstones(t *testing.T) {
+	ctx := context.Background()
+	peer := newInstance(t, 10, time.Minute)
+	local := newInstance(t, 10, time.Minute)
+
+	backend := transfer.NewTombstonesOf[string](local.cache, time.Minute)
+	local.t = &transfer.Transfer{Config: transfer.Config{Timeout: time.Second, SyncBuckets: 16}}
+	local.t.AddCache("greetings", transfer.Of[string](backend))
+
+	require.NoError(t, backend.Delete(ctx, []byte("key1")))
+	require.NoError(t, backend.Delete(ctx, []byte("key2")))
+
+	// Deleted entries are not restored from peer.
+	round, err := local.t.Sync(ctx, peer.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 0, round.Repaired)
+	assert.Equal(t, 8, local.cache.Len())
+
+	_, err = local.cache.Read(ctx, []byte("key1"))
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	// Entry that is written on peer after delete is restored.
+	require.NoError(t, peer.cache.Write(cache.WithTTL(ctx, 2*time.Minute, false), []byte("key2"), "fresh2"))
+
+	round, err = local.t.Sync(ctx, peer.srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 1, round.Repaired)
+
+	v, err := local.cache.Read(ctx, []byte("key2"))
+	require.NoError(t, err)
+	assert.Equal(t, "fresh2", v)
+
+	// Entry that is written right after delete removes the tombstone.
+	require.NoError(t, backend.Delete(ctx, []byte("key3")))
+	require.NoError(t, backend.Write(ctx, []byte("key3"), "local3"))
+	require.NoError(t, peer.cache.Write(ctx, []byte("key3"), "peer3"))
+
+	v, err = local.cache.Read(ctx, []byte("key3"))
+	require.NoError(t, err)
+	assert.Equal(t, "local3", v)
+
+	// Import also skips deleted entries.
+	_, err = local.t.Import(ctx, peer.srv.URL)
+	require.NoError(t, err)
+
+	_, err = local.cache.Read(ctx, []byte("key1"))
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	v, err = local.cache.Read(ctx, []byte("key3"))
+	require.NoError(t, err)
+	assert.Equal(t, "peer3", v)
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/swaggest/usecase"
+	"github.com/swaggest/usecase/status"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+type greetingID struct {
+	ID int `path:"id" minimum:"1"`
+}
+
+// GetGreeting creates use case interactor to find saved greeting.
+func GetGreeting(deps interface {
+	GreetingFinder() greeting.Finder
+},
+) usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, in greetingID, out *greeting.Stored) error {
+		var err error
+
+		*out, err = deps.GreetingFinder().GreetingByID(ctx, in.ID)
+		if errors.Is(err, greeting.ErrNotFound) {
+			return status.Wrap(err, status.NotFound)
+		}
+
+		return err
+	})
+
+	u.SetDescription("Get saved greeting.")
+	u.SetTags("Greeting")
+	u.SetExpectedErrors(status.Unknown, status.InvalidArgument, status.NotFound)
+
+	return u
+}
+
+// DeleteGreeting creates use case interactor to remove saved greeting and its cached value.
+func DeleteGreeting(deps interface {
+	GreetingDeleter() greeting.Deleter
+	GreetingInvalidator() greeting.Invalidator
+},
+) usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, in greetingID, out *greeting.Stored) error {
+		var err error
+
+		*out, err = deps.GreetingDeleter().DeleteGreeting(ctx, in.ID)
+		if errors.Is(err, greeting.ErrNotFound) {
+			return status.Wrap(err, status.NotFound)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return deps.GreetingInvalidator().InvalidateGreeting(ctx, greeting.Params{Name: out.Name, Locale: out.Locale})
+	})
+
+	u.SetDescription("Delete saved greeting, cached value is invalidated and will be built again on next request.")
+	u.SetTags("Greeting")
+	u.SetExpectedErrors(status.Unknown, status.InvalidArgument, status.NotFound)
+
+	return u
+}
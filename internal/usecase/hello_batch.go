@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/bool64/stats"
+	"github.com/swaggest/jsonschema-go"
+	"github.com/swaggest/rest"
+	"github.com/swaggest/usecase"
+	"github.com/swaggest/usecase/status"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// maxBatchSize limits number of greetings in a batch request.
+const maxBatchSize = 100
+
+type helloBatchInput struct {
+	AcceptLanguage string `header:"Accept-Language" description:"Preferred locales, used for items without locale."`
+	helloBatchParams
+}
+
+// UnmarshalJSON decodes request body into params.
+func (in *helloBatchInput) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &in.helloBatchParams)
+}
+
+type helloBatchParams []greeting.Params
+
+// PrepareJSONSchema limits batch size.
+func (helloBatchParams) PrepareJSONSchema(s *jsonschema.Schema) error {
+	s.WithMinItems(1).WithMaxItems(maxBatchSize)
+
+	return nil
+}
+
+type helloBatchItem struct {
+	Greeting *greeting.Greeting `json:"greeting,omitempty"`
+	Error    *rest.ErrResponse  `json:"error,omitempty" description:"Failure of an item, other items are not affected."`
+}
+
+// HelloBatch creates use case interactor to make many greetings at once.
+func HelloBatch(deps interface {
+	StatsTracker() stats.Tracker
+	GreetingBatchMaker() greeting.BatchMaker
+	LocaleCatalog() *greeting.Catalog
+},
+) usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, in helloBatchInput, out *[]helloBatchItem) error {
+		deps.StatsTracker().Add(ctx, "hello_batch", 1)
+
+		params := make([]greeting.Params, len(in.helloBatchParams))
+
+		for i, p := range in.helloBatchParams {
+			if p.Name == "" {
+				p.Name = "World"
+			}
+
+			if p.Locale == "" {
+				p.Locale = deps.LocaleCatalog().Negotiate(in.AcceptLanguage)
+			}
+
+			params[i] = p
+		}
+
+		results := deps.GreetingBatchMaker().HelloBatch(ctx, params)
+		*out = make([]helloBatchItem, len(results))
+
+		for i, r := range results {
+			if r.Err != nil {
+				_, er := rest.Err(helloError(r.Err))
+				(*out)[i].Error = &er
+
+				continue
+			}
+
+			g := r.Greeting
+			(*out)[i].Greeting = &g
+		}
+
+		return nil
+	})
+
+	u.SetDescription("Greeter says hello to many, results are in the order of items and failures are reported per item.")
+	u.SetTags("Greeting")
+	u.SetExpectedErrors(status.InvalidArgument)
+
+	return u
+}
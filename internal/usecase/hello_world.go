@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"errors"
 
 	"github.com/bool64/ctxd"
 	"github.com/bool64/stats"
@@ -14,28 +15,58 @@ type helloDeps interface {
 	CtxdLogger() ctxd.Logger
 	StatsTracker() stats.Tracker
 	GreetingMaker() greeting.Maker
+	LocaleCatalog() *greeting.Catalog
 }
 
 // HelloWorld creates use case interactor.
 func HelloWorld(deps helloDeps) usecase.Interactor {
+	type helloInput struct {
+		greeting.Params
+		AcceptLanguage string `header:"Accept-Language" description:"Preferred locales, used if locale is not set."`
+	}
+
 	type helloOutput struct {
-		Message string `json:"message"`
+		ContentLanguage string `header:"Content-Language" json:"-" description:"Locale of greeting."`
+		greeting.Greeting
 	}
 
-	u := usecase.NewInteractor(func(ctx context.Context, in greeting.Params, out *helloOutput) error {
+	u := usecase.NewInteractor(func(ctx context.Context, in helloInput, out *helloOutput) error {
 		deps.StatsTracker().Add(ctx, "hello", 1)
 		deps.CtxdLogger().Info(ctx, "hello", "name", in.Name)
 
-		msg, err := deps.GreetingMaker().Hello(ctx, in)
+		// Negotiated locale becomes a part of params and of cache key.
+		if in.Locale == "" {
+			in.Locale = deps.LocaleCatalog().Negotiate(in.AcceptLanguage)
+		}
+
+		out.ContentLanguage = in.Locale
 
-		out.Message = msg
+		g, err := deps.GreetingMaker().Hello(ctx, in.Params)
+		if err != nil {
+			return helloError(err)
+		}
 
-		return err
+		out.Greeting = g
+
+		return nil
 	})
 
 	u.SetDescription("Greeter says hello.")
 	u.SetTags("Greeting")
-	u.SetExpectedErrors(status.Unknown, status.InvalidArgument)
+	u.SetExpectedErrors(status.Unknown, status.InvalidArgument, status.Unavailable, status.DeadlineExceeded)
 
 	return u
 }
+
+// helloError sets status of a failed greeting.
+func helloError(err error) error {
+	if errors.Is(err, greeting.ErrOverloaded) {
+		return status.Wrap(err, status.Unavailable)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Wrap(err, status.DeadlineExceeded)
+	}
+
+	return err
+}
@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/swaggest/usecase"
+	"github.com/swaggest/usecase/status"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+// ListGreetings creates use case interactor to browse greetings history.
+func ListGreetings(deps interface {
+	GreetingLister() greeting.Lister
+},
+) usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, in greeting.ListFilter, out *greeting.List) error {
+		var err error
+
+		*out, err = deps.GreetingLister().ListGreetings(ctx, in)
+		if errors.Is(err, greeting.ErrInvalidCursor) {
+			return status.Wrap(err, status.InvalidArgument)
+		}
+
+		return err
+	})
+
+	u.SetDescription("List saved greetings, newest first, with cursor pagination.")
+	u.SetTags("Greeting")
+	u.SetExpectedErrors(status.Unknown, status.InvalidArgument)
+
+	return u
+}
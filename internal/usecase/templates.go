@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/swaggest/usecase"
+	"github.com/swaggest/usecase/status"
+	"github.com/vearutop/cache-story/internal/domain/greeting"
+)
+
+type templateLocale struct {
+	Locale string `path:"locale" catalog:"locale"`
+}
+
+type templateDeps interface {
+	LocaleCatalog() *greeting.Catalog
+	TemplateStorage() greeting.TemplateStorage
+	TemplateInvalidator() greeting.TemplateInvalidator
+	GreetingInvalidator() greeting.Invalidator
+}
+
+// ListTemplates creates use case interactor to list greeting templates.
+func ListTemplates(deps interface {
+	TemplateStorage() greeting.TemplateStorage
+},
+) usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, _ struct{}, out *[]greeting.Template) error {
+		var err error
+
+		*out, err = deps.TemplateStorage().ListTemplates(ctx)
+
+		return err
+	})
+
+	u.SetDescription("List greeting templates.")
+	u.SetTags("Template")
+
+	return u
+}
+
+// GetTemplate creates use case interactor to find greeting template.
+func GetTemplate(deps interface {
+	TemplateStorage() greeting.TemplateStorage
+},
+) usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, in templateLocale, out *greeting.Template) error {
+		var err error
+
+		*out, err = deps.TemplateStorage().TemplateByLocale(ctx, in.Locale)
+		if errors.Is(err, greeting.ErrTemplateNotFound) {
+			return status.Wrap(err, status.NotFound)
+		}
+
+		return err
+	})
+
+	u.SetDescription("Get greeting template of a locale.")
+	u.SetTags("Template")
+	u.SetExpectedErrors(status.Unknown, status.InvalidArgument, status.NotFound)
+
+	return u
+}
+
+// PutTemplate creates use case interactor to create or update greeting template.
+func PutTemplate(deps templateDeps) usecase.Interactor {
+	type putTemplateInput struct {
+		templateLocale
+		Text string `json:"text" required:"true" maxLength:"255" description:"Greeting with {name} placeholder."`
+	}
+
+	u := usecase.NewInteractor(func(ctx context.Context, in putTemplateInput, out *greeting.Template) error {
+		var err error
+
+		*out, err = deps.TemplateStorage().SaveTemplate(ctx, greeting.Template{Locale: in.Locale, Text: in.Text})
+		if errors.Is(err, greeting.ErrInvalidTemplate) {
+			return status.Wrap(err, status.InvalidArgument)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return invalidateTemplate(ctx, deps, in.Locale)
+	})
+
+	u.SetDescription("Create or update greeting template, cached and stored greetings of the locale and its dependents are invalidated.")
+	u.SetTags("Template")
+	u.SetExpectedErrors(status.Unknown, status.InvalidArgument)
+
+	return u
+}
+
+// DeleteTemplate creates use case interactor to remove greeting template.
+func DeleteTemplate(deps templateDeps) usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, in templateLocale, out *greeting.Template) error {
+		var err error
+
+		*out, err = deps.TemplateStorage().DeleteTemplate(ctx, in.Locale)
+		if errors.Is(err, greeting.ErrTemplateNotFound) {
+			return status.Wrap(err, status.NotFound)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return invalidateTemplate(ctx, deps, in.Locale)
+	})
+
+	u.SetDescription("Delete greeting template, default template of locale catalog is used instead.")
+	u.SetTags("Template")
+	u.SetExpectedErrors(status.Unknown, status.InvalidArgument, status.NotFound)
+
+	return u
+}
+
+// invalidateTemplate removes cached template before dependent greetings,
+// so that greetings are not built again with previous template.
+//
+// Greetings of locales that fall back to changed one are invalidated too.
+func invalidateTemplate(ctx context.Context, deps templateDeps, locale string) error {
+	if err := deps.TemplateInvalidator().InvalidateTemplate(ctx, locale); err != nil {
+		return err
+	}
+
+	for _, l := range deps.LocaleCatalog().Dependents(locale) {
+		if err := deps.GreetingInvalidator().InvalidateLocale(ctx, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}